@@ -4,17 +4,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	da "github.com/direktiv/direktiv-apps/pkg/direktivapps"
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	_ "github.com/microsoft/go-mssqldb"
+	_ "modernc.org/sqlite"
 )
 
 type input struct {
 	Data struct {
 		DB    map[string]interface{} `json:"db"`
 		Where string                 `json:"where"`
+		Args  []interface{}          `json:"args"`
 		Table string                 `json:"table"`
+		// Deny is set by the query service if the policies allow no
+		// record, the database is not queried.
+		Deny bool `json:"deny"`
 	} `json:"data"`
 }
 
@@ -30,6 +39,54 @@ func reportError(w http.ResponseWriter, code string, err error) {
 	da.RespondWithError(w, fmt.Sprintf(errCode, code), err.Error())
 }
 
+// dataSource returns the driver and connection string for the registry
+// config, selected by its `dialect` field. The dialect names are the ones
+// understood by rulejson.DialectByName in the query service.
+func dataSource(db map[string]interface{}) (string, string, error) {
+	dialect, _ := db["dialect"].(string)
+
+	switch strings.ToLower(dialect) {
+	case "", "postgres", "postgresql":
+		return "postgres", fmt.Sprintf("host=%s port=%s user=%s "+
+			"password=%s dbname=%s sslmode=require",
+			db["host"], db["port"], db["username"], db["password"], db["database"]), nil
+	case "mysql", "mariadb":
+		return "mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?tls=true",
+			db["username"], db["password"], db["host"], db["port"], db["database"]), nil
+	case "sqlite", "sqlite3":
+		return "sqlite", fmt.Sprintf("file:%s?mode=ro", db["database"]), nil
+	case "sqlserver", "mssql":
+		u := &url.URL{
+			Scheme:   "sqlserver",
+			User:     url.UserPassword(fmt.Sprint(db["username"]), fmt.Sprint(db["password"])),
+			Host:     fmt.Sprintf("%s:%s", db["host"], db["port"]),
+			RawQuery: url.Values{"database": {fmt.Sprint(db["database"])}, "encrypt": {"true"}}.Encode(),
+		}
+		return "sqlserver", u.String(), nil
+	}
+
+	return "", "", fmt.Errorf("unsupported database dialect `%s`", dialect)
+}
+
+// quoteTable quotes the table name from the bucket path for driver like
+// the query service quotes columns, a schema-qualified name per part.
+func quoteTable(driver string, table string) string {
+	open, close := `"`, `"`
+	switch driver {
+	case "mysql":
+		open, close = "`", "`"
+	case "sqlserver":
+		open, close = "[", "]"
+	}
+
+	parts := strings.Split(table, ".")
+	for i := range parts {
+		parts[i] = open + strings.ReplaceAll(parts[i], close, close+close) + close
+	}
+
+	return strings.Join(parts, ".")
+}
+
 func coreLogic(w http.ResponseWriter, r *http.Request) {
 	obj := new(input)
 	aid, err := da.Unmarshal(obj, r)
@@ -38,15 +95,26 @@ func coreLogic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	da.LogDouble(aid, "executing sql")
+	// without a WHERE clause the policies can't be applied, like a denial
+	// no record is returned.
+	if obj.Data.Deny || strings.TrimSpace(obj.Data.Where) == "" {
+		da.LogDouble(aid, "no access, skipping sql")
+		w.Write([]byte("[]"))
+		return
+	}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
-		"password=%s dbname=%s sslmode=require",
-		obj.Data.DB["host"], obj.Data.DB["port"], obj.Data.DB["username"], obj.Data.DB["password"], obj.Data.DB["database"])
+	da.LogDouble(aid, "executing sql")
 
-	fmt.Println(psqlInfo)
+	driver, dsn, err := dataSource(obj.Data.DB)
+	if err != nil {
+		da.WriteError(da.ActionError{
+			"io.direktiv.conn.error",
+			err.Error(),
+		})
+		return
+	}
 
-	db, err := sqlx.Connect("postgres", psqlInfo)
+	db, err := sqlx.Connect(driver, dsn)
 	if err != nil {
 		da.WriteError(da.ActionError{
 			"io.direktiv.conn.error",
@@ -55,9 +123,9 @@ func coreLogic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	selectStmt := fmt.Sprintf(`select * from %s where %s`, obj.Data.Table, obj.Data.Where)
+	selectStmt := fmt.Sprintf(`select * from %s where %s`, quoteTable(driver, obj.Data.Table), obj.Data.Where)
 
-	rows, err := db.Queryx(selectStmt)
+	rows, err := db.Queryx(selectStmt, obj.Data.Args...)
 	if err != nil {
 		fmt.Println(err)
 		da.WriteError(da.ActionError{
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 	"query/pkg/rulejson"
-	"strings"
 
 	// "github.com/Azure/azure-sdk-for-go/sdk/azcore/internal/exported"
 
@@ -14,31 +13,83 @@ import (
 )
 
 type UserAttribute struct {
-	Description  string `json:"description"`
-	Name         string `json:"name"`
-	Value        string `json:"value"`
-	ID           any    `json:"id"`
-	SrcAttr      any    `json:"srcAttr"`
-	SrcDirectory any    `json:"srcDirectory"`
-	SrcName      any    `json:"srcName"`
-	Typ          any    `json:"type"`
-	RegExp       any    `json:"regExp"`
-	Project      any    `json:"project"`
+	Description  string          `json:"description"`
+	Name         string          `json:"name"`
+	Value        rulejson.Values `json:"value"`
+	ID           any             `json:"id"`
+	SrcAttr      any             `json:"srcAttr"`
+	SrcDirectory any             `json:"srcDirectory"`
+	SrcName      any             `json:"srcName"`
+	Typ          any             `json:"type"`
+	RegExp       any             `json:"regExp"`
+	Project      any             `json:"project"`
 }
 
 type input struct {
 	Query struct {
 		Policies []rulejson.Rule `json:"policies"`
 		User     []UserAttribute `json:"user"`
+		Dialect  string          `json:"dialect"`
+		// Format selects the output, a WHERE clause with sql (default) or
+		// a filter document in MongoDB extended JSON with mongo.
+		Format string `json:"format"`
+		// Env holds the env.* attributes, like the client address.
+		Env map[string]rulejson.Values `json:"env"`
+		// Missing is the policy for user and env attributes missing from
+		// the input: deny (default), error or null.
+		Missing string `json:"missing"`
+		// Combining is the algorithm combining the effects of the
+		// policies: deny-overrides (default), permit-overrides or
+		// first-applicable.
+		Combining string `json:"combining"`
+		// Search adds an Elasticsearch bool query of the policies to the
+		// output, to filter search indices like the tables.
+		Search bool `json:"search"`
+		// Explain adds the evaluation trace of every policy to the output.
+		Explain bool `json:"explain"`
+		// Record is the record /decision decides access to, keyed by column.
+		Record map[string]any `json:"record"`
+		// Catalog, if set, describes the columns of the tables and the
+		// user attributes, policies referring to others are rejected.
+		Catalog *rulejson.Catalog `json:"catalog"`
+		// Table is the table of the catalog the policies are for.
+		Table string `json:"table"`
 	} `json:"query"`
 }
 
+// attributes returns the user and env attributes of the query.
+func (obj *input) attributes() rulejson.Input {
+	attrs := rulejson.Input{}
+	for i := range obj.Query.User {
+		// repeated attributes collect their values, like one entry per group.
+		name := "user." + obj.Query.User[i].Name
+		attrs[name] = append(attrs[name], obj.Query.User[i].Value...)
+	}
+	for name, values := range obj.Query.Env {
+		attrs["env."+name] = values
+	}
+
+	return attrs
+}
+
 const (
 	errCode = "com.query.%s"
 )
 
 func main() {
-	da.StartServer(coreLogic)
+	da.StartServer(route)
+}
+
+// route serves decisions for a single record on /decision, all other
+// requests compile WHERE clauses. Workflows only use the latter,
+// /decision is called directly, see README.md.
+func route(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/decision" {
+		decisionLogic(w, r)
+		return
+	}
+
+	coreLogic(w, r)
 }
 
 func reportError(w http.ResponseWriter, code string, err error) {
@@ -57,46 +108,220 @@ func coreLogic(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("2")
 	da.LogDouble(aid, "Hello")
 
-	userAttrs := map[string]string{}
-	for i := range obj.Query.User {
-		userAttrs["user."+obj.Query.User[i].Name] = obj.Query.User[i].Value
-	}
+	userAttrs := obj.attributes()
 	fmt.Println("3")
-	whereClauses := []string{}
+	dialect, err := rulejson.DialectByName(obj.Query.Dialect)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	missing, err := rulejson.MissingPolicyByName(obj.Query.Missing)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	combining, err := rulejson.CombiningAlgorithmByName(obj.Query.Combining)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+
+	if obj.Query.Format != "" && obj.Query.Format != "sql" && obj.Query.Format != "mongo" {
+		writeError(w, fmt.Sprintf("unknown format `%s`, must be `sql` or `mongo`", obj.Query.Format))
+		return
+	}
+
+	if errs := validatePolicies(obj.Query.Policies, obj.Query.Catalog, obj.Query.Table); len(errs) != 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	var (
+		residuals []rulejson.Rule
+		traces    []*rulejson.Trace
+	)
 	for i := range obj.Query.Policies {
 		rule := &obj.Query.Policies[i]
-		rErr := rulejson.Validate(rule)
-		if len(rErr) != 0 {
-			writeError(w, fmt.Sprintf("Policy validation error: %v", rErr))
+		if obj.Query.Explain {
+			trace, err := rule.Explain(userAttrs, rulejson.WithMissing(missing))
+			if err != nil {
+				writeError(w, fmt.Sprintf("Policy evaluation error: %v", err))
+				return
+			}
+			traces = append(traces, trace)
+		}
+
+		rule, err = rule.EvaluateInput(userAttrs, rulejson.WithMissing(missing))
+		if err != nil {
+			writeError(w, fmt.Sprintf("Policy evaluation error: %v", err))
 			return
 		}
 
-		rule, err = rule.Evaluate(userAttrs)
+		residuals = append(residuals, *rule)
+	}
+
+	// the policies compile to a single rule, like (permit1 OR permit2) AND
+	// NOT (deny1 OR deny2). Without a policy no record matches.
+	rule := rulejson.Combine(residuals, combining).Simplify()
+	// a rule that allows no record is flagged, the execute service skips
+	// the database for it. The compiled outputs are still valid, FALSE for
+	// SQL.
+	value, constant := rule.Constant()
+	deny := constant && !value
+
+	var search any
+	if obj.Query.Search {
+		elastic := &rulejson.ElasticBuilder{}
+		search, err = elastic.Compile(rule)
 		if err != nil {
-			writeError(w, fmt.Sprintf("Policy evaluation error: %v", rErr))
+			writeError(w, fmt.Sprintf("Policy compilation error: %v", err))
 			return
 		}
+	}
 
-		str := strings.ReplaceAll(rule.Stringer(), "data.", "")
-		whereClauses = append(whereClauses, str)
+	if obj.Query.Format == "mongo" {
+		mongo := &rulejson.MongoBuilder{ExtendedJSON: true}
+		filter, err := mongo.Compile(rule)
+		if err != nil {
+			writeError(w, fmt.Sprintf("Policy compilation error: %v", err))
+			return
+		}
+		data, err := json.Marshal(filter)
+		if err != nil {
+			writeError(w, fmt.Sprintf("Policy compilation error: %v", err))
+			return
+		}
+		writeJSON(w, filter, base64.StdEncoding.EncodeToString(data), nil, deny, search, traces)
+		return
 	}
 
-	result := strings.Join(whereClauses, " OR ")
+	sql := &rulejson.SQLBuilder{Dialect: dialect}
+	result, err := sql.Compile(rule)
+	if err != nil {
+		writeError(w, fmt.Sprintf("Policy compilation error: %v", err))
+		return
+	}
 	encoded := base64.StdEncoding.EncodeToString([]byte(result))
 	fmt.Println("4")
-	writeJSON(w, result, encoded)
+	writeJSON(w, result, encoded, sql.Args(), deny, search, traces)
+}
+
+// decisionLogic decides whether the user may access the record of the
+// query. The effects of the policies are combined like the WHERE clause
+// they compile to, the policy that decided is reported.
+func decisionLogic(w http.ResponseWriter, r *http.Request) {
+	obj := new(input)
+	_, err := da.Unmarshal(obj, r)
+	if err != nil {
+		reportError(w, "inputUnmarshal", err)
+		return
+	}
+	if obj.Query.Record == nil {
+		writeError(w, "a decision requires a `record`")
+		return
+	}
+	missing, err := rulejson.MissingPolicyByName(obj.Query.Missing)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	combining, err := rulejson.CombiningAlgorithmByName(obj.Query.Combining)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+
+	if errs := validatePolicies(obj.Query.Policies, obj.Query.Catalog, obj.Query.Table); len(errs) != 0 {
+		writeValidationError(w, errs)
+		return
+	}
+
+	userAttrs := obj.attributes()
+	var traces []*rulejson.Trace
+	for i := range obj.Query.Policies {
+		rule := &obj.Query.Policies[i]
+		if obj.Query.Explain {
+			trace, err := rule.Explain(userAttrs, rulejson.WithMissing(missing))
+			if err != nil {
+				writeError(w, fmt.Sprintf("Policy evaluation error: %v", err))
+				return
+			}
+			traces = append(traces, trace)
+		}
+	}
+
+	allowed, policy, err := rulejson.Decide(obj.Query.Policies, combining, userAttrs, obj.Query.Record, rulejson.WithMissing(missing))
+	if err != nil {
+		writeError(w, fmt.Sprintf("Policy evaluation error: %v", err))
+		return
+	}
+	decision, name := "deny", ""
+	if allowed {
+		decision = "allow"
+	}
+	if policy != nil {
+		name = policy.Name
+	}
+
+	writeDecision(w, decision, name, traces)
 }
 
-func writeJSON(w http.ResponseWriter, data string, base64 string) {
+// validatePolicies validates all policies, against the catalog if it is
+// set. The paths of the errors point into the request like
+// /query/policies/0/items/1.
+func validatePolicies(policies []rulejson.Rule, catalog *rulejson.Catalog, table string) []rulejson.RuleError {
+	validate := rulejson.Validate
+	if catalog != nil {
+		validate = func(rule *rulejson.Rule) []rulejson.RuleError {
+			return catalog.Validate(rule, table)
+		}
+	}
+
+	var errs []rulejson.RuleError
+	for i := range policies {
+		for _, rErr := range validate(&policies[i]) {
+			rErr.Path = fmt.Sprintf("/query/policies/%d", i) + rErr.Path
+			errs = append(errs, rErr)
+		}
+	}
+
+	return errs
+}
+
+func writeDecision(w http.ResponseWriter, decision string, policy string, traces []*rulejson.Trace) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
 	payLoad := struct {
-		Data   any    `json:"data"`
-		Base64 string `json:"base64"`
+		Decision string            `json:"decision"`
+		Policy   string            `json:"policy,omitempty"`
+		Traces   []*rulejson.Trace `json:"traces,omitempty"`
+	}{
+		Decision: decision,
+		Policy:   policy,
+		Traces:   traces,
+	}
+	_ = json.NewEncoder(w).Encode(payLoad)
+}
+
+func writeJSON(w http.ResponseWriter, data any, base64 string, args []any, deny bool, search any, traces []*rulejson.Trace) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	payLoad := struct {
+		Data   any               `json:"data"`
+		Base64 string            `json:"base64"`
+		Args   []any             `json:"args"`
+		Deny   bool              `json:"deny"`
+		Search any               `json:"search,omitempty"`
+		Traces []*rulejson.Trace `json:"traces,omitempty"`
 	}{
 		Data:   data,
 		Base64: base64,
+		Args:   args,
+		Deny:   deny,
+		Search: search,
+		Traces: traces,
 	}
 	_ = json.NewEncoder(w).Encode(payLoad)
 }
@@ -112,3 +337,17 @@ func writeError(w http.ResponseWriter, err string) {
 	}
 	_ = json.NewEncoder(w).Encode(payLoad)
 }
+
+func writeValidationError(w http.ResponseWriter, errs []rulejson.RuleError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	payLoad := struct {
+		Error  string               `json:"error"`
+		Errors []rulejson.RuleError `json:"errors"`
+	}{
+		Error:  "Policy validation error",
+		Errors: errs,
+	}
+	_ = json.NewEncoder(w).Encode(payLoad)
+}
@@ -2,8 +2,9 @@ package rulejson
 
 import (
 	"fmt"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 )
 
 type Target map[string]string
@@ -12,98 +13,463 @@ type TargetValue struct {
 	Value string `json:"value"`
 }
 
+type TargetValues struct {
+	Values []string `json:"values"`
+}
+
+// TargetNull is the assert of operators that don't compare against a
+// value, like isNull. Its assert may be omitted.
+type TargetNull struct{}
+
+// TargetRelative is the assert of the relative time operators. For
+// withinLast and olderThan Duration is the length of the window before the
+// evaluation time, for beforeNow and afterNow an optional offset added to
+// it, e.g. -P7D.
+type TargetRelative struct {
+	Duration string `json:"duration"`
+}
+
+// offset returns the shift from the evaluation time to the time the
+// attribute is compared against.
+func (t *TargetRelative) offset(operator string) time.Duration {
+	d, _ := ParseDuration(t.Duration)
+	if operator == "withinLast" || operator == "olderThan" {
+		return -d
+	}
+
+	return d
+}
+
+// threshold returns the time the attribute is compared against, for kind
+// date the UTC day it falls on.
+func (t *TargetRelative) threshold(operator string, kind string, now time.Time) time.Time {
+	return relativeThreshold(now, t.offset(operator), kind)
+}
+
+func relativeThreshold(now time.Time, offset time.Duration, kind string) time.Time {
+	threshold := now.Add(offset)
+	if kind == KindDate {
+		threshold = threshold.UTC().Truncate(24 * time.Hour)
+	}
+
+	return threshold
+}
+
+// relativeOperators maps the relative time operators to the comparison
+// between attribute and threshold.
+var relativeOperators = map[string]string{
+	"withinLast": ">=",
+	"olderThan":  "<",
+	"beforeNow":  "<",
+	"afterNow":   ">",
+}
+
 type TargetRange struct {
 	From string `json:"from"`
 	To   string `json:"to"`
 }
 
-func sqlCompileTarget(operator string, a any, attr RuleAttribute) string {
+// TargetWildcard holds a pattern in the syntax described at Wildcard.
+type TargetWildcard struct {
+	Value      string `json:"value"`
+	IgnoreCase bool   `json:"ignoreCase"`
+}
+
+// Wildcard parses the pattern, lower cased if the match ignores case.
+func (t *TargetWildcard) Wildcard() (Wildcard, error) {
+	if t.IgnoreCase {
+		return ParseWildcard(strings.ToLower(t.Value))
+	}
+
+	return ParseWildcard(t.Value)
+}
+
+func sqlCompileTarget(b *SQLBuilder, operator string, a any, attr RuleAttribute) (string, error) {
 	switch operator {
 	case "equal":
-		return sqlCompileTargetEqual(a, attr)
+		return sqlCompileTargetCompare(b, "=", a, attr), nil
+	case "notEqual":
+		return sqlCompileTargetCompare(b, "<>", a, attr), nil
+	case "greaterThan":
+		return sqlCompileTargetCompare(b, ">", a, attr), nil
+	case "lessThan":
+		return sqlCompileTargetCompare(b, "<", a, attr), nil
+	case "in":
+		return sqlCompileTargetIn(b, "IN", a, attr), nil
+	case "notIn":
+		return sqlCompileTargetIn(b, "NOT IN", a, attr), nil
 	case "range":
-		return sqlCompileTargetRange(a, attr)
+		return sqlCompileTargetRange(b, a, attr), nil
 	case "isSubstringOf":
-		return sqlCompileTargetIsSubstringOf(a, attr)
+		return sqlCompileTargetIsSubstringOf(b, a, attr), nil
+	case "startsWith":
+		return sqlCompileTargetStartsWith(b, a, attr), nil
+	case "endsWith":
+		return sqlCompileTargetEndsWith(b, a, attr), nil
 	case "matchesWildcard":
-		return sqlCompileTargetMatchesWildcard(a, attr)
+		return sqlCompileTargetMatchesWildcard(b, a, attr)
+	case "isNull":
+		return sqlCompileTargetIsNull(b, a, attr), nil
+	case "withinLast", "olderThan", "beforeNow", "afterNow":
+		return sqlCompileTargetRelative(b, operator, a, attr), nil
+	case "anyOf", "intersects":
+		return sqlCompileTargetAnyOf(b, a, attr), nil
+	case "allOf":
+		return sqlCompileTargetAllOf(b, a, attr), nil
 	default:
-		return "N/A"
+		return "", fmt.Errorf("unknown operator `%s`", operator)
+	}
+}
+
+// assertValues returns the values of a parsed assert that are compared
+// as the attribute kind, pattern operators always work on strings.
+func assertValues(operator string, a any) []string {
+	switch target := a.(type) {
+	case *TargetValue:
+		if slices.Contains([]string{"equal", "notEqual", "greaterThan", "lessThan"}, operator) {
+			return []string{target.Value}
+		}
+	case *TargetValues:
+		return target.Values
+	case *TargetRange:
+		return []string{target.From, target.To}
+	}
+
+	return nil
+}
+
+// evaluateValues evaluates operator against the values of a possibly
+// multi-valued attribute, see valuesMatcher.
+func evaluateValues(operator string, a any, values []string, kind string, now time.Time) bool {
+	return valuesMatcher(operator, a, kind)(values, now)
+}
+
+// valuesMatcher resolves operator and its parsed assert a into a function
+// deciding the values of an attribute. Set operators compare the values
+// as a whole, negated operators hold if they hold for every value and the
+// remaining ones if they hold for any. The assert is parsed as kind once,
+// the function only parses the values of the attribute.
+func valuesMatcher(operator string, a any, kind string) func(values []string, now time.Time) bool {
+	switch operator {
+	case "anyOf", "intersects":
+		//nolint:forcetypeassert
+		targets := parseTargets(kind, a.(*TargetValues).Values...)
+
+		return func(values []string, _ time.Time) bool { return targetAnyOf(targets, values, kind) }
+	case "allOf":
+		//nolint:forcetypeassert
+		targets := parseTargets(kind, a.(*TargetValues).Values...)
+
+		return func(values []string, _ time.Time) bool { return targetAllOf(targets, values, kind) }
+	case "isNull":
+		return func(values []string, _ time.Time) bool { return len(values) == 0 }
+	}
+
+	holds := valueMatcher(operator, a, kind)
+	if operator == "notEqual" || operator == "notIn" {
+		return func(values []string, now time.Time) bool {
+			for _, value := range values {
+				if !holds(value, now) {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return func(values []string, now time.Time) bool {
+		for _, value := range values {
+			if holds(value, now) {
+				return true
+			}
+		}
+
+		return false
 	}
 }
 
 func evaluateTarget(operator string, a any, input string, kind string) bool {
+	return valueMatcher(operator, a, kind)(input, time.Time{})
+}
+
+// valueMatcher returns the function deciding operator for a single value.
+// Operators that don't compare single values never hold.
+func valueMatcher(operator string, a any, kind string) func(value string, now time.Time) bool {
 	switch operator {
-	case "equal":
-		return targetEqual(a, input, kind)
+	case "equal", "greaterThan", "lessThan":
+		//nolint:forcetypeassert
+		target := parseTargets(kind, a.(*TargetValue).Value)[0]
+		holds := orderings[targetOrderings[operator]]
+
+		return func(value string, _ time.Time) bool {
+			c, ok := compareTarget(value, target, kind)
+
+			return ok && holds(c)
+		}
+	case "notEqual":
+		equal := valueMatcher("equal", a, kind)
+
+		return func(value string, now time.Time) bool { return !equal(value, now) }
+	case "in":
+		//nolint:forcetypeassert
+		targets := parseTargets(kind, a.(*TargetValues).Values...)
+
+		return func(value string, _ time.Time) bool { return targetIn(targets, value, kind) }
+	case "notIn":
+		in := valueMatcher("in", a, kind)
+
+		return func(value string, now time.Time) bool { return !in(value, now) }
 	case "range":
-		return targetRange(a, input, kind)
+		//nolint:forcetypeassert
+		target := a.(*TargetRange)
+		bounds := parseTargets(kind, target.From, target.To)
+
+		return func(value string, _ time.Time) bool { return targetRange(bounds[0], bounds[1], value, kind) }
 	case "isSubstringOf":
-		return targetIsSubstringOf(a, input, kind)
+		//nolint:forcetypeassert
+		target := a.(*TargetValue)
+
+		return func(value string, _ time.Time) bool { return strings.Contains(target.Value, value) }
+	case "startsWith":
+		//nolint:forcetypeassert
+		target := a.(*TargetValue)
+
+		return func(value string, _ time.Time) bool { return strings.HasPrefix(value, target.Value) }
+	case "endsWith":
+		//nolint:forcetypeassert
+		target := a.(*TargetValue)
+
+		return func(value string, _ time.Time) bool { return strings.HasSuffix(value, target.Value) }
 	case "matchesWildcard":
-		return targetMatchesWildcard(a, input, kind)
-	default:
+		//nolint:forcetypeassert
+		target := a.(*TargetWildcard)
+		w, err := target.Wildcard()
+		if err != nil {
+			break
+		}
+
+		return func(value string, _ time.Time) bool {
+			if target.IgnoreCase {
+				value = strings.ToLower(value)
+			}
+
+			return w.Match(value)
+		}
+	case "withinLast", "olderThan", "beforeNow", "afterNow":
+		//nolint:forcetypeassert
+		offset := a.(*TargetRelative).offset(operator)
+		holds := orderings[relativeOperators[operator]]
+
+		return func(value string, now time.Time) bool {
+			return targetRelative(offset, holds, value, kind, now)
+		}
+	}
+
+	return func(string, time.Time) bool { return false }
+}
+
+// targetOrderings maps the operators comparing against a single assert
+// value to the comparison between attribute and value.
+var targetOrderings = map[string]string{
+	"equal":       "=",
+	"greaterThan": ">",
+	"lessThan":    "<",
+}
+
+// orderings decides a comparison from the order of its operands.
+var orderings = map[string]func(c int) bool{
+	"=":  func(c int) bool { return c == 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+// parseTargets parses assert values as kind, values that can't be parsed
+// are nil and match nothing.
+func parseTargets(kind string, values ...string) []any {
+	targets := make([]any, len(values))
+	for i, value := range values {
+		if target, err := parseValue(kind, value); err == nil {
+			targets[i] = target
+		}
+	}
+
+	return targets
+}
+
+// compareTarget orders value relative to a parsed assert value. It returns
+// false if value can't be parsed as kind or target is nil.
+func compareTarget(value string, target any, kind string) (int, bool) {
+	if target == nil {
+		return 0, false
+	}
+	v, err := parseValue(kind, value)
+	if err != nil {
+		return 0, false
+	}
+
+	return compareParsed(v, target)
+}
+
+func targetIn(targets []any, value string, kind string) bool {
+	return slices.ContainsFunc(targets, func(t any) bool {
+		c, ok := compareTarget(value, t, kind)
+
+		return ok && c == 0
+	})
+}
+
+// targetAnyOf holds if one of values is one of the assert values. It
+// decides anyOf and intersects, which is the same test named for sets: the
+// values of the attribute and of the assert intersect.
+func targetAnyOf(targets []any, values []string, kind string) bool {
+	return slices.ContainsFunc(values, func(value string) bool {
+		return targetIn(targets, value, kind)
+	})
+}
+
+func targetAllOf(targets []any, values []string, kind string) bool {
+	for _, t := range targets {
+		if !slices.ContainsFunc(values, func(value string) bool {
+			c, ok := compareTarget(value, t, kind)
+
+			return ok && c == 0
+		}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func targetRelative(offset time.Duration, holds func(c int) bool, value string, kind string, now time.Time) bool {
+	v, err := parseValue(kind, value)
+	if err != nil {
 		return false
 	}
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+
+	return holds(t.Compare(relativeThreshold(now, offset, kind)))
+}
+
+func targetRange(from any, to any, value string, kind string) bool {
+	c, ok := compareTarget(value, from, kind)
+	if !ok || c < 0 {
+		return false
+	}
+	c, ok = compareTarget(value, to, kind)
+
+	return ok && c <= 0
 }
 
-func targetEqual(a any, value string, kind string) bool {
+func sqlCompileTargetCompare(b *SQLBuilder, op string, a any, atrr RuleAttribute) string {
+	//nolint:forcetypeassert
 	target := a.(*TargetValue)
-	return target.Value == value
+
+	return fmt.Sprintf(`%s %s %s`, b.column(atrr, op), op, b.bind(target.Value, atrr.Kind))
 }
 
-func targetRange(a any, value string, kind string) bool {
+func sqlCompileTargetIn(b *SQLBuilder, op string, a any, atrr RuleAttribute) string {
 	//nolint:forcetypeassert
-	target := a.(*TargetRange)
+	target := a.(*TargetValues)
+
+	values := b.bindAll(target.Values, atrr.Kind)
 
-	v1, _ := strconv.ParseFloat(target.From, 64)
-	v2, _ := strconv.ParseFloat(target.To, 64)
-	v, _ := strconv.ParseFloat(value, 64)
+	return fmt.Sprintf(`%s %s (%s)`, b.column(atrr, op), op, strings.Join(values, ", "))
+}
+
+func sqlCompileTargetRange(b *SQLBuilder, a any, atrr RuleAttribute) string {
+	//nolint:forcetypeassert
+	target := a.(*TargetRange)
 
-	return v >= v1 && v <= v2
+	return fmt.Sprintf(`%s BETWEEN %s AND %s`, b.column(atrr, "BETWEEN"), b.bind(target.From, atrr.Kind), b.bind(target.To, atrr.Kind))
 }
 
-func targetIsSubstringOf(a any, value string, kind string) bool {
+func sqlCompileTargetIsSubstringOf(b *SQLBuilder, a any, atrr RuleAttribute) string {
 	//nolint:forcetypeassert
 	target := a.(*TargetValue)
 
-	return strings.Contains(target.Value, value)
+	return b.dialect().Position(b.column(atrr, "="), b.bind(target.Value, "string"))
 }
 
-func targetMatchesWildcard(a any, value string, kind string) bool {
+func sqlCompileTargetStartsWith(b *SQLBuilder, a any, atrr RuleAttribute) string {
 	//nolint:forcetypeassert
 	target := a.(*TargetValue)
 
-	return strings.Contains(target.Value, value)
+	return b.like(atrr.Name, Wildcard{{literal: target.Value}, {kind: wildcardAny}}, false)
 }
 
-func sqlCompileTargetEqual(a any, atrr RuleAttribute) string {
+func sqlCompileTargetEndsWith(b *SQLBuilder, a any, atrr RuleAttribute) string {
 	//nolint:forcetypeassert
 	target := a.(*TargetValue)
-	if atrr.Kind == "string" {
-		return fmt.Sprintf(`%s = "%v"`, atrr.Name, target.Value)
-	} else {
-		return fmt.Sprintf(`%s = %v`, atrr.Name, target.Value)
+
+	return b.like(atrr.Name, Wildcard{{kind: wildcardAny}, {literal: target.Value}}, false)
+}
+
+func sqlCompileTargetMatchesWildcard(b *SQLBuilder, a any, atrr RuleAttribute) (string, error) {
+	//nolint:forcetypeassert
+	target := a.(*TargetWildcard)
+
+	w, err := target.Wildcard()
+	if err != nil {
+		return "", fmt.Errorf("invalid wildcard pattern: %w", err)
 	}
+
+	return b.like(atrr.Name, w, target.IgnoreCase), nil
 }
 
-func sqlCompileTargetRange(a any, atrr RuleAttribute) string {
+func sqlCompileTargetIsNull(b *SQLBuilder, a any, atrr RuleAttribute) string {
+	return fmt.Sprintf(`%s IS NULL`, b.ident(atrr.Name))
+}
+
+func sqlCompileTargetRelative(b *SQLBuilder, operator string, a any, atrr RuleAttribute) string {
 	//nolint:forcetypeassert
-	target := a.(*TargetRange)
+	target := a.(*TargetRelative)
+
+	var threshold string
+	if b.Clock != nil {
+		t := target.threshold(operator, atrr.Kind, b.Clock())
+		threshold = b.bind(formatTime(t, atrr.Kind), atrr.Kind)
+	} else {
+		threshold = b.dialect().Now(target.offset(operator), atrr.Kind, b.binder())
+	}
 
-	return fmt.Sprintf(`%s BETWEEN %v and %v`, atrr.Name, target.From, target.To)
+	return fmt.Sprintf(`%s %s %s`, b.ident(atrr.Name), relativeOperators[operator], threshold)
 }
 
-func sqlCompileTargetIsSubstringOf(a any, atrr RuleAttribute) string {
+func sqlCompileTargetAnyOf(b *SQLBuilder, a any, atrr RuleAttribute) string {
 	//nolint:forcetypeassert
-	target := a.(*TargetValue)
+	target := a.(*TargetValues)
 
-	return fmt.Sprintf(`%s LIKE %%%v%%`, atrr.Name, target.Value)
+	if atrr.Multi {
+		return b.dialect().Overlaps(b.ident(atrr.Name), b.bindAll(target.Values, atrr.Kind))
+	}
+
+	return sqlCompileTargetIn(b, "IN", a, atrr)
 }
 
-func sqlCompileTargetMatchesWildcard(a any, atrr RuleAttribute) string {
+func sqlCompileTargetAllOf(b *SQLBuilder, a any, atrr RuleAttribute) string {
 	//nolint:forcetypeassert
-	target := a.(*TargetValue)
+	target := a.(*TargetValues)
+
+	values := b.bindAll(target.Values, atrr.Kind)
+	if atrr.Multi {
+		return b.dialect().ContainsAll(b.ident(atrr.Name), values)
+	}
+
+	// a single value column holds all values only if they are all equal.
+	conditions := make([]string, len(values))
+	for i := range values {
+		conditions[i] = fmt.Sprintf(`%s = %s`, b.column(atrr, "="), values[i])
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
 
-	return fmt.Sprintf(`%s LIKE '%v'`, atrr.Name, target.Value)
+	return "( " + strings.Join(conditions, " AND ") + " )"
 }
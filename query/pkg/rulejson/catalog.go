@@ -0,0 +1,125 @@
+package rulejson
+
+import (
+	"strconv"
+)
+
+// Catalog describes the attributes policies may refer to: the columns of
+// the tables of a bucket and the known user and env attributes. A nil
+// list isn't checked, a catalog without env attributes accepts all of
+// them.
+type Catalog struct {
+	// Tables maps the tables of the bucket to their columns, the data
+	// attributes of policies for the table.
+	Tables map[string][]CatalogAttribute `json:"tables"`
+	// User lists the user attribute definitions, the attributes of the
+	// user part of a query.
+	User []CatalogAttribute `json:"user"`
+	Env  []CatalogAttribute `json:"env"`
+}
+
+// CatalogAttribute is a column or a user or env attribute definition,
+// named without namespace.
+type CatalogAttribute struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// one of the Kind constants, defaults to string.
+	Kind  string `json:"kind"`
+	Multi bool   `json:"multi"`
+}
+
+// Validate validates rule like the package level Validate and checks the
+// attributes it refers to against the catalog, data attributes against
+// the columns of table, which is required if the catalog lists tables.
+// Unknown attributes and attributes with another kind than in the catalog
+// are reported.
+func (c *Catalog) Validate(rule *Rule, table string) []RuleError {
+	errs := Validate(rule)
+
+	columns, ok := c.Tables[table]
+	switch {
+	case table == "" && c.Tables != nil:
+		errs = append(errs, RuleError{
+			Name: rule.Name,
+			Code: CodeTableRequired,
+			Err:  "table required to check data attributes against the catalog",
+		})
+	case !ok && c.Tables != nil:
+		errs = append(errs, RuleError{
+			Name: rule.Name,
+			Code: CodeTableUnknown,
+			Err:  "unknown table `" + table + "`",
+		})
+	}
+	attributes := map[string][]CatalogAttribute{
+		NamespaceData: columns,
+		NamespaceUser: c.User,
+		NamespaceEnv:  c.Env,
+	}
+	checkCatalog(rule, "", attributes, &errs)
+
+	return errs
+}
+
+// checkCatalog checks the attributes of rule and its items against the
+// catalog attributes of their namespace.
+func checkCatalog(rule *Rule, path string, attributes map[string][]CatalogAttribute, errs *[]RuleError) {
+	var attrs []RuleAttribute
+	switch rule.Type {
+	case "attribute":
+		attrs = []RuleAttribute{rule.Attribute}
+	case "comparison":
+		attrs = rule.Attributes
+	}
+
+	for _, attr := range attrs {
+		namespace, name, ok := namespaceOf(attr.Name)
+		// attributes without namespace are reported by validate.
+		if !ok || attributes[namespace] == nil {
+			continue
+		}
+		def, found := lookupCatalog(attributes[namespace], name)
+		switch {
+		case !found:
+			*errs = append(*errs, RuleError{
+				Path: path,
+				Name: rule.Name,
+				Code: CodeAttributeUnknown,
+				Err:  "unknown attribute `" + attr.Name + "`",
+			})
+		case kindOf(attr) != kindOf(RuleAttribute{Kind: def.Kind}) || attr.Multi != def.Multi:
+			*errs = append(*errs, RuleError{
+				Path: path,
+				Name: rule.Name,
+				Code: CodeAttributeKindMismatch,
+				Err:  "attribute `" + attr.Name + "` is declared as " + catalogKind(attr.Kind, attr.Multi) + ", the catalog defines " + catalogKind(def.Kind, def.Multi),
+			})
+		}
+	}
+
+	for i := range rule.Items {
+		checkCatalog(&rule.Items[i], path+"/items/"+strconv.Itoa(i), attributes, errs)
+	}
+}
+
+func lookupCatalog(attributes []CatalogAttribute, name string) (CatalogAttribute, bool) {
+	for _, attr := range attributes {
+		if attr.Name == name {
+			return attr, true
+		}
+	}
+
+	return CatalogAttribute{}, false
+}
+
+// catalogKind renders a kind like `number` or a list of `number`.
+func catalogKind(kind string, multi bool) string {
+	if kind == "" {
+		kind = KindString
+	}
+	if multi {
+		return "a list of `" + kind + "`"
+	}
+
+	return "`" + kind + "`"
+}
@@ -0,0 +1,123 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+
+	catalog := &Catalog{}
+	err := json.Unmarshal([]byte(`{
+		"tables": {
+			"orders": [
+				{"name": "work_order", "kind": "string"},
+				{"name": "amount", "kind": "number"},
+				{"name": "tags", "multi": true},
+				{"name": "region"}
+			]
+		},
+		"user": [
+			{"name": "city", "description": "the city of the office"},
+			{"name": "level", "kind": "number"}
+		]
+	}`), catalog)
+	if err != nil {
+		t.Fatalf("failed to unmarshal catalog: %v", err)
+	}
+
+	return catalog
+}
+
+func TestCatalogValidate(t *testing.T) {
+	catalog := testCatalog(t)
+
+	tests := []struct {
+		name  string
+		src   string
+		table string
+		want  []RuleError
+	}{
+		{
+			name:  "known attributes",
+			src:   `data.work_order = "A-1" and data.amount:number > 10 and data.tags[] anyOf ("red") and user.city = data.region and env.ip is null`,
+			table: "orders",
+		},
+		{
+			name:  "unknown column",
+			src:   `data.work_order = "A-1" or data.workorder = "A-1"`,
+			table: "orders",
+			want:  []RuleError{{Path: "/items/1", Code: CodeAttributeUnknown, Err: "unknown attribute `data.workorder`"}},
+		},
+		{
+			name:  "unknown user attribute",
+			src:   `user.town = data.region`,
+			table: "orders",
+			want:  []RuleError{{Code: CodeAttributeUnknown, Err: "unknown attribute `user.town`"}},
+		},
+		{
+			name:  "kind differs",
+			src:   `data.amount > 10`,
+			table: "orders",
+			want:  []RuleError{{Code: CodeAttributeKindMismatch, Err: "attribute `data.amount` is declared as `string`, the catalog defines `number`"}},
+		},
+		{
+			name:  "multi differs",
+			src:   `not data.tags = "red"`,
+			table: "orders",
+			want:  []RuleError{{Path: "/items/0", Code: CodeAttributeKindMismatch, Err: "attribute `data.tags` is declared as `string`, the catalog defines a list of `string`"}},
+		},
+		{
+			name:  "comparison",
+			src:   `user.level:number <= data.amount:number and user.level = data.region`,
+			table: "orders",
+			want:  []RuleError{{Path: "/items/1", Code: CodeAttributeKindMismatch, Err: "attribute `user.level` is declared as `string`, the catalog defines `number`"}},
+		},
+		{
+			name:  "unknown table",
+			src:   `data.anything = "x"`,
+			table: "invoices",
+			want:  []RuleError{{Code: CodeTableUnknown, Err: "unknown table `invoices`"}},
+		},
+		{
+			name: "no table",
+			src:  `data.anything = "x"`,
+			want: []RuleError{{Code: CodeTableRequired, Err: "table required to check data attributes against the catalog"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseExpression(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			got := catalog.Validate(rule, tt.table)
+			for i := range got {
+				got[i].Name = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogValidateStructure(t *testing.T) {
+	// the catalog checks come after the checks of Validate.
+	rule := &Rule{
+		Name:      "amount",
+		Type:      "attribute",
+		Operator:  "range",
+		Attribute: RuleAttribute{Name: "data.amount"},
+		Assert:    json.RawMessage(`{"from": "1", "to": "3"}`),
+	}
+	want := []RuleError{
+		{Name: "amount", Code: CodeOperatorKindInvalid, Err: "rule with operator `range` must have an attribute of kind `number`, `date`, `datetime` or `duration`"},
+		{Name: "amount", Code: CodeAttributeKindMismatch, Err: "attribute `data.amount` is declared as `string`, the catalog defines `number`"},
+	}
+	if got := testCatalog(t).Validate(rule, "orders"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,484 @@
+package rulejson
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CedarExporter translates rules into Cedar policies that permit access if
+// the rule holds. User attributes are attributes of the principal, data
+// attributes of the resource and env attributes of the context, nested
+// like user.address.city at principal.address.city. Numbers are Longs and
+// multi-valued attributes sets. A policy with effect deny is exported as a
+// forbid policy. A missing user or env attribute decides the rules reading
+// it like MissingDeny, missing data attributes follow the NULL convention
+// of SQLBuilder.
+//
+// Cedar has no fractional numbers, no order of strings, no dates and no
+// clock, rules relying on them are reported as errors.
+type CedarExporter struct {
+	// Action, if set, limits the policy to an action, like
+	// Action::"read".
+	Action string
+}
+
+// cedarPrincipals maps the attribute namespaces to the entities and
+// records holding them.
+var cedarPrincipals = map[string]string{
+	NamespaceUser: "principal",
+	NamespaceData: "resource",
+	NamespaceEnv:  "context",
+}
+
+// Export renders rule, which must be validated, as a Cedar policy.
+func (e *CedarExporter) Export(rule *Rule) (string, error) {
+	condition, err := cedarCondition(rule, rule.Effect == EffectDeny)
+	if err != nil {
+		return "", err
+	}
+
+	action := "action"
+	if e.Action != "" {
+		action = "action == " + e.Action
+	}
+
+	effect := "permit"
+	if rule.Effect == EffectDeny {
+		effect = "forbid"
+	}
+
+	var b strings.Builder
+	b.WriteString(effect + " (\n  principal,\n  " + action + ",\n  resource\n)\n")
+	b.WriteString("when {\n  " + condition + "\n};\n")
+
+	return b.String(), nil
+}
+
+// cedarCondition returns the condition that holds if rule does. missing is
+// the value of the rules reading a missing user or env attribute, like
+// evaluation.missingValue.
+func cedarCondition(rule *Rule, missing bool) (string, error) {
+	if rule.Decision.Decided() {
+		return strconv.FormatBool(rule.Decision == True), nil
+	}
+
+	switch rule.Type {
+	case "bool":
+		switch rule.Operator {
+		case "true", "false":
+			return rule.Operator, nil
+		}
+
+		return "", fmt.Errorf("rule `%s` has invalid bool value `%s`", rule.Name, rule.Operator)
+	case "attribute":
+		condition, err := cedarTarget(rule)
+		if err != nil {
+			return "", err
+		}
+
+		return cedarMissing(rule, condition, missing)
+	case "comparison":
+		condition, err := cedarComparison(rule)
+		if err != nil {
+			return "", err
+		}
+
+		return cedarMissing(rule, condition, missing)
+	case "group":
+		if rule.Operator == "NOT" {
+			if len(rule.Items) != 1 {
+				return "", fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+			}
+			item, err := cedarCondition(&rule.Items[0], !missing)
+			if err != nil {
+				return "", err
+			}
+
+			return "!" + cedarParens(item), nil
+		}
+
+		items := make([]string, len(rule.Items))
+		for i := range rule.Items {
+			item, err := cedarCondition(&rule.Items[i], missing)
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		if len(items) == 1 {
+			return items[0], nil
+		}
+
+		switch rule.Operator {
+		case "AND":
+			return "(" + strings.Join(items, " && ") + ")", nil
+		case "OR":
+			return "(" + strings.Join(items, " || ") + ")", nil
+		}
+
+		return "", fmt.Errorf("rule `%s` has unknown group operator `%s`", rule.Name, rule.Operator)
+	}
+
+	return "", fmt.Errorf("allowed rule types are `attribute`, `bool`, `comparison` or `group` got: `%s`", rule.Type)
+}
+
+// cedarMissing returns the condition of a leaf rule, which holds like
+// missing if one of its user or env attributes is missing and like
+// condition otherwise. The attributes are checked before the condition
+// accesses them, a policy that errors doesn't apply.
+func cedarMissing(rule *Rule, condition string, missing bool) (string, error) {
+	attrs := inputAttributes(rule)
+	if len(attrs) == 0 {
+		return condition, nil
+	}
+
+	checks := make([]string, len(attrs))
+	for i := range attrs {
+		_, has, err := cedarPath(attrs[i])
+		if err != nil {
+			return "", err
+		}
+		checks[i] = has
+	}
+	if missing {
+		return "(!(" + strings.Join(checks, " && ") + ") || " + condition + ")", nil
+	}
+
+	return cedarGuard(condition, checks...), nil
+}
+
+// cedarParens wraps condition in parentheses unless it already is.
+func cedarParens(condition string) string {
+	if strings.HasPrefix(condition, "(") && strings.HasSuffix(condition, ")") {
+		return condition
+	}
+
+	return "(" + condition + ")"
+}
+
+// cedarString quotes value as a Cedar string literal, escaping the
+// characters of patterns in like with pattern set.
+func cedarString(value string, pattern bool) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteString(`\` + string(r))
+		case r == '*' && pattern:
+			b.WriteString(`\*`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u{%x}`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// cedarValue returns the literal of value for attributes of kind.
+func cedarValue(value string, kind string) (string, error) {
+	switch kind {
+	case "", KindString:
+		return cedarString(value, false), nil
+	case KindNumber:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("assert value `%s` is not a valid %s", value, kind)
+		}
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return "", fmt.Errorf("number `%s` is not a Long, Cedar has no fractional numbers", value)
+		}
+
+		return strconv.FormatInt(int64(v), 10), nil
+	}
+
+	return "", fmt.Errorf("values of kind `%s` can't be represented in Cedar", kind)
+}
+
+func cedarSet(values []string, kind string) (string, error) {
+	literals := make([]string, len(values))
+	for i := range values {
+		literal, err := cedarValue(values[i], kind)
+		if err != nil {
+			return "", err
+		}
+		literals[i] = literal
+	}
+
+	return "[" + strings.Join(literals, ", ") + "]", nil
+}
+
+// cedarPath returns the access to the attribute name and the check that
+// it, and the records holding it, are present.
+func cedarPath(name string) (access string, has string, err error) {
+	namespace, column, ok := namespaceOf(name)
+	if !ok {
+		return "", "", fmt.Errorf("attribute `%s` has no namespace", name)
+	}
+
+	access = cedarPrincipals[namespace]
+	var checks []string
+	for _, segment := range strings.Split(column, ".") {
+		if identifier.MatchString(segment) {
+			checks = append(checks, access+" has "+segment)
+			access += "." + segment
+		} else {
+			checks = append(checks, access+" has "+cedarString(segment, false))
+			access += "[" + cedarString(segment, false) + "]"
+		}
+	}
+
+	return access, strings.Join(checks, " && "), nil
+}
+
+// cedarAttribute returns the access to attr and, for resource
+// attributes, the check that it is present.
+func cedarAttribute(attr RuleAttribute) (access string, has string, err error) {
+	if kind := kindOf(attr); kind != KindString && kind != KindNumber {
+		return "", "", fmt.Errorf("values of kind `%s` can't be represented in Cedar", kind)
+	}
+	access, has, err = cedarPath(attr.Name)
+	if err != nil {
+		return "", "", err
+	}
+	if namespace, _, _ := namespaceOf(attr.Name); namespace != NamespaceData {
+		has = ""
+	}
+
+	return access, has, nil
+}
+
+// cedarLike translates w into the pattern of a like operation. Cedar
+// patterns have no wildcard for a single character.
+func cedarLike(w Wildcard) (string, error) {
+	for _, token := range w {
+		if token.kind == wildcardOne {
+			return "", errors.New("Cedar patterns have no wildcard for a single character")
+		}
+	}
+
+	return `"` + w.translate(func(literal string) string {
+		quoted := cedarString(literal, true)
+
+		return quoted[1 : len(quoted)-1]
+	}, "*", "") + `"`, nil
+}
+
+// cedarGuard checks the resource attributes a condition accesses first,
+// has is empty for other attributes.
+func cedarGuard(condition string, has ...string) string {
+	var checks []string
+	for _, h := range has {
+		if h != "" {
+			checks = append(checks, h)
+		}
+	}
+	if len(checks) == 0 {
+		return condition
+	}
+
+	return "(" + strings.Join(checks, " && ") + " && " + condition + ")"
+}
+
+// cedarOrdered fails for kinds Cedar can't order, only Longs are.
+func cedarOrdered(kind string) error {
+	if kind != KindNumber {
+		return errors.New("only numbers can be ordered in Cedar")
+	}
+
+	return nil
+}
+
+func cedarTarget(rule *Rule) (string, error) {
+	if rule.ParsedTarget == nil {
+		return "", fmt.Errorf("rule `%s` has no parsed target, was it validated?", rule.Name)
+	}
+	if _, ok := rule.ParsedTarget.(*TargetNull); ok {
+		// isNull holds for attributes of any kind.
+		_, has, err := cedarPath(rule.Attribute.Name)
+		if err != nil {
+			return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+		}
+
+		return "!(" + has + ")", nil
+	}
+
+	condition, err := cedarTargetCondition(rule)
+	if err != nil {
+		return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+
+	return condition, nil
+}
+
+func cedarTargetCondition(rule *Rule) (string, error) {
+	x, has, err := cedarAttribute(rule.Attribute)
+	if err != nil {
+		return "", err
+	}
+	kind := rule.Attribute.Kind
+
+	switch target := rule.ParsedTarget.(type) {
+	case *TargetValue:
+		switch rule.Operator {
+		case "isSubstringOf":
+			return "", errors.New("Cedar has no operator testing for substrings")
+		case "startsWith", "endsWith":
+			w := Wildcard{{literal: target.Value}, {kind: wildcardAny}}
+			if rule.Operator == "endsWith" {
+				w = Wildcard{{kind: wildcardAny}, {literal: target.Value}}
+			}
+			pattern, err := cedarLike(w)
+			if err != nil {
+				return "", err
+			}
+
+			return cedarGuard(x+" like "+pattern, has), nil
+		}
+		value, err := cedarValue(target.Value, kind)
+		if err != nil {
+			return "", err
+		}
+		switch rule.Operator {
+		case "equal":
+			return cedarGuard(x+" == "+value, has), nil
+		case "notEqual":
+			return cedarGuard(x+" != "+value, has), nil
+		case "greaterThan", "lessThan":
+			if err := cedarOrdered(kind); err != nil {
+				return "", err
+			}
+			op := ">"
+			if rule.Operator == "lessThan" {
+				op = "<"
+			}
+
+			return cedarGuard(x+" "+op+" "+value, has), nil
+		}
+	case *TargetValues:
+		values, err := cedarSet(target.Values, kind)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case rule.Attribute.Multi && rule.Operator == "allOf":
+			return cedarGuard(x+".containsAll("+values+")", has), nil
+		case rule.Attribute.Multi:
+			return cedarGuard(x+".containsAny("+values+")", has), nil
+		}
+		switch rule.Operator {
+		case "in", "anyOf", "intersects":
+			return cedarGuard(values+".contains("+x+")", has), nil
+		case "notIn":
+			return cedarGuard("!"+values+".contains("+x+")", has), nil
+		case "allOf":
+			// a single value holds all values only if they are all equal.
+			conditions := make([]string, len(target.Values))
+			for i := range target.Values {
+				value, err := cedarValue(target.Values[i], kind)
+				if err != nil {
+					return "", err
+				}
+				conditions[i] = x + " == " + value
+			}
+
+			return cedarGuard(strings.Join(conditions, " && "), has), nil
+		}
+	case *TargetRange:
+		if err := cedarOrdered(kind); err != nil {
+			return "", err
+		}
+		from, err := cedarValue(target.From, kind)
+		if err != nil {
+			return "", err
+		}
+		to, err := cedarValue(target.To, kind)
+		if err != nil {
+			return "", err
+		}
+
+		return cedarGuard(x+" >= "+from+" && "+x+" <= "+to, has), nil
+	case *TargetWildcard:
+		w, err := target.Wildcard()
+		if err != nil {
+			return "", fmt.Errorf("invalid wildcard pattern: %w", err)
+		}
+		if target.IgnoreCase {
+			return "", errors.New("Cedar patterns can't ignore case")
+		}
+		pattern, err := cedarLike(w)
+		if err != nil {
+			return "", err
+		}
+
+		return cedarGuard(x+" like "+pattern, has), nil
+	case *TargetRelative:
+		return "", fmt.Errorf("operator `%s` needs the current time, Cedar policies have no clock", rule.Operator)
+	}
+
+	return "", fmt.Errorf("unknown operator `%s`", rule.Operator)
+}
+
+func cedarComparison(rule *Rule) (string, error) {
+	if len(rule.Attributes) != 2 {
+		return "", fmt.Errorf("rule `%s` with type `comparison` must have exactly two attributes", rule.Name)
+	}
+	if len(rule.Resolved) != 0 {
+		return "", fmt.Errorf("rule `%s` has resolved user values, export the rule before evaluation", rule.Name)
+	}
+
+	left, right := rule.Attributes[0], rule.Attributes[1]
+	operator := rule.Operator
+	if operator == "contains" {
+		left, right, operator = right, left, "in"
+	}
+	if operator == "in" {
+		// only a multi-valued attribute holds a list, a single value must
+		// be equal.
+		operator = "equal"
+		if right.Multi {
+			operator = "in"
+		}
+	}
+
+	l, lHas, err := cedarAttribute(left)
+	if err != nil {
+		return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+	r, rHas, err := cedarAttribute(right)
+	if err != nil {
+		return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+	if operator == "in" {
+		return cedarGuard(r+".contains("+l+")", lHas, rHas), nil
+	}
+
+	op, ok := comparisonOperators[operator]
+	if !ok {
+		return "", fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+	}
+	switch op {
+	case "=":
+		op = "=="
+	case "<>":
+		op = "!="
+	default:
+		if err := cedarOrdered(kindOf(left)); err != nil {
+			return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+		}
+	}
+
+	return cedarGuard(l+" "+op+" "+r, lHas, rHas), nil
+}
@@ -0,0 +1,162 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCedarExporter(t *testing.T) {
+	attribute := func(name string, kind string, operator string, assert string) *Rule {
+		rule := &Rule{
+			Name:      "rule",
+			Type:      "attribute",
+			Operator:  operator,
+			Attribute: RuleAttribute{Name: name, Kind: kind},
+		}
+		if assert != "" {
+			rule.Assert = json.RawMessage(assert)
+		}
+
+		return rule
+	}
+
+	tests := []struct {
+		name string
+		rule *Rule
+		want string
+	}{
+		{
+			name: "number",
+			rule: attribute("data.age", KindNumber, "greaterThan", `{"value": "18.0"}`),
+			want: `(resource has age && resource.age > 18)`,
+		},
+		{
+			name: "nested field",
+			rule: attribute("data.address.zip-code", "", "equal", `{"value": "2\"0"}`),
+			want: `(resource has address && resource.address has "zip-code" && resource.address["zip-code"] == "2\"0")`,
+		},
+		{
+			name: "notIn",
+			rule: attribute("data.city", "", "notIn", `{"values": ["Hamburg", "Berlin"]}`),
+			want: `(resource has city && !["Hamburg", "Berlin"].contains(resource.city))`,
+		},
+		{
+			name: "startsWith escapes",
+			rule: attribute("data.path", "", "startsWith", `{"value": "/a*"}`),
+			want: `(resource has path && resource.path like "/a\**")`,
+		},
+		{
+			name: "wildcard",
+			rule: attribute("data.name", "", "matchesWildcard", `{"value": "J*n\\?"}`),
+			want: `(resource has name && resource.name like "J*n?")`,
+		},
+		{
+			name: "isNull",
+			rule: attribute("data.city", "", "isNull", ""),
+			want: `!(resource has city)`,
+		},
+		{
+			name: "multi anyOf",
+			rule: &Rule{
+				Type:      "attribute",
+				Operator:  "anyOf",
+				Attribute: RuleAttribute{Name: "data.tags", Multi: true},
+				Assert:    json.RawMessage(`{"values": ["red", "blue"]}`),
+			},
+			want: `(resource has tags && resource.tags.containsAny(["red", "blue"]))`,
+		},
+		{
+			// under NOT a missing attribute makes the item hold, so the
+			// negation doesn't.
+			name: "not",
+			rule: &Rule{Type: "group", Operator: "NOT", Items: []Rule{*attribute("env.network", "", "equal", `{"value": "public"}`)}},
+			want: `!(!(context has network) || context.network == "public")`,
+		},
+		{
+			name: "user attributes in OR",
+			rule: &Rule{Type: "group", Operator: "OR", Items: []Rule{
+				*attribute("user.a", "", "equal", `{"value": "x"}`),
+				*attribute("user.b", "", "equal", `{"value": "y"}`),
+			}},
+			want: `((principal has a && principal.a == "x") || (principal has b && principal.b == "y"))`,
+		},
+		{
+			name: "comparison",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "lte",
+				Attributes: []RuleAttribute{{Name: "data.level", Kind: KindNumber}, {Name: "user.level", Kind: KindNumber}},
+			},
+			want: `(principal has level && (resource has level && resource.level <= principal.level))`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			got, err := (&CedarExporter{Action: `Action::"read"`}).Export(tt.rule)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			want := "permit (\n  principal,\n  action == Action::\"read\",\n  resource\n)\nwhen {\n  " + tt.want + "\n};\n"
+			if got != want {
+				t.Errorf("Export() got = >%v<, want >%v<", got, want)
+			}
+		})
+	}
+}
+
+func TestCedarExporterErrors(t *testing.T) {
+	attribute := func(kind string, operator string, assert string) *Rule {
+		return &Rule{
+			Type:      "attribute",
+			Operator:  operator,
+			Attribute: RuleAttribute{Name: "data.field", Kind: kind},
+			Assert:    json.RawMessage(assert),
+		}
+	}
+
+	tests := []struct {
+		name string
+		rule *Rule
+	}{
+		{name: "fraction", rule: attribute(KindNumber, "equal", `{"value": "1.5"}`)},
+		{name: "string order", rule: attribute("", "lessThan", `{"value": "m"}`)},
+		{name: "date", rule: attribute(KindDate, "equal", `{"value": "2024-01-01"}`)},
+		{name: "relative time", rule: attribute(KindDateTime, "withinLast", `{"duration": "P7D"}`)},
+		{name: "substring", rule: attribute("", "isSubstringOf", `{"value": "abc"}`)},
+		{name: "single character wildcard", rule: attribute("", "matchesWildcard", `{"value": "a?c"}`)},
+		{name: "ignore case", rule: attribute("", "matchesWildcard", `{"value": "a*", "ignoreCase": true}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			if _, err := (&CedarExporter{}).Export(tt.rule); err == nil {
+				t.Errorf("Export() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestCedarExporterDeny(t *testing.T) {
+	rule, err := ParseExpression(`deny user.age:number < data.min_age:number`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(rule); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	got, err := (&CedarExporter{}).Export(rule)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	// a missing principal attribute applies the forbid.
+	want := "forbid (\n  principal,\n  action,\n  resource\n)\n" +
+		"when {\n  (!(principal has age) || (resource has min_age && principal.age < resource.min_age))\n};\n"
+	if got != want {
+		t.Errorf("Export() got = >%v<, want >%v<", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+package rulejson
+
+import (
+	"fmt"
+)
+
+// Policy effects. A policy with effect permit grants access to the
+// records it holds for, one with effect deny takes it away. Rules without
+// an effect permit.
+const (
+	EffectPermit = "permit"
+	EffectDeny   = "deny"
+)
+
+// validateEffect checks the effect of rule, root is set for the rule of
+// the policy itself.
+func validateEffect(rule *Rule, root bool, errs *[]RuleError) {
+	switch {
+	case rule.Effect == "":
+	case !root:
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeEffectNotAllowed,
+			Err:  "only policies, not the items of a group, have an effect",
+		})
+	case rule.Effect != EffectPermit && rule.Effect != EffectDeny:
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeEffectInvalid,
+			Err:  "invalid effect `" + rule.Effect + "`, must be `permit` or `deny`",
+		})
+	}
+}
+
+// CombiningAlgorithm decides how the effects of several policies combine
+// into access to a record. Without any policy that holds access is denied.
+type CombiningAlgorithm int
+
+const (
+	// DenyOverrides allows access if a permit holds and no deny does.
+	DenyOverrides CombiningAlgorithm = iota
+	// PermitOverrides allows access if a permit holds, denies only matter
+	// for records no permit holds for.
+	PermitOverrides
+	// FirstApplicable decides by the effect of the first policy that
+	// holds.
+	FirstApplicable
+)
+
+var combiningAlgorithmNames = map[CombiningAlgorithm]string{
+	DenyOverrides:   "deny-overrides",
+	PermitOverrides: "permit-overrides",
+	FirstApplicable: "first-applicable",
+}
+
+func (a CombiningAlgorithm) String() string {
+	return combiningAlgorithmNames[a]
+}
+
+// CombiningAlgorithmByName returns the algorithm named deny-overrides,
+// permit-overrides or first-applicable. An empty name selects
+// DenyOverrides.
+func CombiningAlgorithmByName(name string) (CombiningAlgorithm, error) {
+	if name == "" {
+		return DenyOverrides, nil
+	}
+	for algorithm, algorithmName := range combiningAlgorithmNames {
+		if name == algorithmName {
+			return algorithm, nil
+		}
+	}
+
+	return DenyOverrides, fmt.Errorf("unknown combining algorithm `%s`, must be `deny-overrides`, `permit-overrides` or `first-applicable`", name)
+}
+
+// Combine merges policies, usually the results of EvaluateInput, into a
+// single rule that holds for the records algorithm allows access to. With
+// DenyOverrides it is (permit1 OR permit2) AND NOT (deny1 OR deny2), like
+// every rule it compiles to every target and evaluates with EvaluateRow.
+// The policies are copied, the result has no effect.
+func Combine(policies []Rule, algorithm CombiningAlgorithm) *Rule {
+	var permits, denies []Rule
+	if algorithm == FirstApplicable {
+		// a permit applies if no deny before it holds, permits before it
+		// would already have allowed access.
+		for i := range policies {
+			policy := combinedItem(&policies[i])
+			if policies[i].Effect == EffectDeny {
+				denies = append(denies, *policy)
+
+				continue
+			}
+			if len(denies) != 0 {
+				policy = &Rule{Name: policy.Name, Type: "group", Operator: "AND", Items: []Rule{
+					*policy,
+					*combinedNot(denies),
+				}}
+			}
+			permits = append(permits, *policy)
+		}
+
+		return combinedAny("policies", permits)
+	}
+
+	for i := range policies {
+		if policies[i].Effect == EffectDeny {
+			denies = append(denies, *combinedItem(&policies[i]))
+		} else {
+			permits = append(permits, *combinedItem(&policies[i]))
+		}
+	}
+
+	permit := combinedAny("permits", permits)
+	if algorithm == PermitOverrides || len(denies) == 0 {
+		return permit
+	}
+
+	return &Rule{Name: "policies", Type: "group", Operator: "AND", Items: []Rule{*permit, *combinedNot(denies)}}
+}
+
+// combinedItem copies policy without its effect.
+func combinedItem(policy *Rule) *Rule {
+	cop := &Rule{}
+	cloneRule(policy, cop)
+	cop.Effect = ""
+
+	return cop
+}
+
+// combinedAny holds if one of rules does, it is false without rules.
+func combinedAny(name string, rules []Rule) *Rule {
+	switch len(rules) {
+	case 0:
+		return constantRule(name, false)
+	case 1:
+		return &rules[0]
+	}
+
+	return &Rule{Name: name, Type: "group", Operator: "OR", Items: rules}
+}
+
+func combinedNot(denies []Rule) *Rule {
+	return &Rule{Name: "denies", Type: "group", Operator: "NOT", Items: []Rule{*combinedAny("denies", denies)}}
+}
+
+// Decide decides access to a single record like Combine and EvaluateRow,
+// every policy is evaluated on its own. It returns the policy that decided
+// access, nil if no policy holds.
+func Decide(policies []Rule, algorithm CombiningAlgorithm, input Input, row map[string]any, opts ...EvaluateOption) (bool, *Rule, error) {
+	var firstPermit, firstDeny *Rule
+	for i := range policies {
+		holds, err := policies[i].EvaluateRow(input, row, opts...)
+		if err != nil {
+			return false, nil, err
+		}
+		if !holds {
+			continue
+		}
+
+		deny := policies[i].Effect == EffectDeny
+		switch {
+		case algorithm == FirstApplicable:
+			return !deny, &policies[i], nil
+		case deny && algorithm == DenyOverrides:
+			return false, &policies[i], nil
+		case !deny && algorithm == PermitOverrides:
+			return true, &policies[i], nil
+		case deny && firstDeny == nil:
+			firstDeny = &policies[i]
+		case !deny && firstPermit == nil:
+			firstPermit = &policies[i]
+		}
+	}
+
+	switch {
+	case firstPermit != nil:
+		return true, firstPermit, nil
+	case firstDeny != nil:
+		return false, firstDeny, nil
+	}
+
+	return false, nil, nil
+}
@@ -0,0 +1,295 @@
+package rulejson
+
+import (
+	"testing"
+)
+
+func combiningPolicies(t *testing.T) []Rule {
+	t.Helper()
+
+	var policies []Rule
+	for _, src := range []string{
+		`permit @berlin data.city = "Berlin"`,
+		`deny @blocked data.status = "blocked"`,
+		`permit @admin user.role = "admin"`,
+		`deny @archived (data.status = "archived" and user.role != "admin")`,
+	} {
+		policy, err := ParseExpression(src)
+		if err != nil {
+			t.Fatalf("ParseExpression(%s): %v", src, err)
+		}
+		if errs := Validate(policy); len(errs) != 0 {
+			t.Fatalf("Validate(%s): %v", src, errs)
+		}
+		policies = append(policies, *policy)
+	}
+
+	return policies
+}
+
+func TestCombine(t *testing.T) {
+	policies := combiningPolicies(t)
+	evaluated := make([]Rule, len(policies))
+	for i := range policies {
+		rule, err := policies[i].EvaluateInput(Input{"user.role": {"editor"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		evaluated[i] = *rule
+	}
+
+	tests := []struct {
+		algorithm CombiningAlgorithm
+		want      string
+	}{
+		{
+			algorithm: DenyOverrides,
+			want:      `( data.city = 'Berlin' AND ( NOT ( data.status = 'blocked' OR data.status = 'archived' ) ) )`,
+		},
+		{
+			algorithm: PermitOverrides,
+			want:      `data.city = 'Berlin'`,
+		},
+		{
+			algorithm: FirstApplicable,
+			want:      `data.city = 'Berlin'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm.String(), func(t *testing.T) {
+			got := Combine(evaluated, tt.algorithm).Simplify().Stringer()
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCombineFirstApplicable(t *testing.T) {
+	policies := combiningPolicies(t)
+	// the deny of blocked records only applies to the permits after it,
+	// the deny of archived ones to none.
+	var evaluated []Rule
+	for _, i := range []int{1, 0, 3, 2} {
+		rule, err := policies[i].EvaluateInput(Input{"user.role": {"admin"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		evaluated = append(evaluated, *rule)
+	}
+
+	got := Combine(evaluated, FirstApplicable).Simplify().Stringer()
+	want := `( NOT data.status = 'blocked' )`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestCombineEmpty(t *testing.T) {
+	for _, algorithm := range []CombiningAlgorithm{DenyOverrides, PermitOverrides, FirstApplicable} {
+		holds, err := Combine(nil, algorithm).EvaluateRow(Input{}, map[string]any{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if holds {
+			t.Errorf("%s: no policy allows access", algorithm)
+		}
+	}
+}
+
+func TestDecide(t *testing.T) {
+	policies := combiningPolicies(t)
+	rows := []map[string]any{
+		{"city": "Berlin", "status": "active"},
+		{"city": "Berlin", "status": "blocked"},
+		{"city": "Berlin", "status": "archived"},
+		{"city": "Hamburg", "status": "active"},
+		{"city": "Hamburg", "status": "blocked"},
+		{"city": "Berlin"},
+		{},
+	}
+	tests := []struct {
+		name      string
+		algorithm CombiningAlgorithm
+		input     Input
+		want      []bool
+		// the names of the deciding policies, per row
+		policies []string
+	}{
+		{
+			name:      "deny overrides",
+			algorithm: DenyOverrides,
+			input:     Input{"user.role": {"editor"}},
+			want:      []bool{true, false, false, false, false, true, false},
+			policies:  []string{"berlin", "blocked", "archived", "", "blocked", "berlin", ""},
+		},
+		{
+			name:      "deny overrides admin",
+			algorithm: DenyOverrides,
+			input:     Input{"user.role": {"admin"}},
+			want:      []bool{true, false, true, true, false, true, true},
+			policies:  []string{"berlin", "blocked", "berlin", "admin", "blocked", "berlin", "admin"},
+		},
+		{
+			name:      "permit overrides",
+			algorithm: PermitOverrides,
+			input:     Input{"user.role": {"editor"}},
+			want:      []bool{true, true, true, false, false, true, false},
+			policies:  []string{"berlin", "berlin", "berlin", "", "blocked", "berlin", ""},
+		},
+		{
+			name:      "first applicable",
+			algorithm: FirstApplicable,
+			input:     Input{"user.role": {"admin"}},
+			want:      []bool{true, true, true, true, false, true, true},
+			policies:  []string{"berlin", "berlin", "berlin", "admin", "blocked", "berlin", "admin"},
+		},
+		{
+			name:      "missing attribute applies deny",
+			algorithm: DenyOverrides,
+			input:     Input{},
+			want:      []bool{true, false, false, false, false, true, false},
+			policies:  []string{"berlin", "blocked", "archived", "", "blocked", "berlin", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluated := make([]Rule, len(policies))
+			for i := range policies {
+				rule, err := policies[i].EvaluateInput(tt.input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				evaluated[i] = *rule
+			}
+			combined := Combine(evaluated, tt.algorithm)
+
+			for i, row := range rows {
+				allowed, policy, err := Decide(policies, tt.algorithm, tt.input, row)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if allowed != tt.want[i] {
+					t.Errorf("row %v: Decide got %t, want %t", row, allowed, tt.want[i])
+				}
+				name := ""
+				if policy != nil {
+					name = policy.Name
+				}
+				if name != tt.policies[i] {
+					t.Errorf("row %v: decided by %q, want %q", row, name, tt.policies[i])
+				}
+
+				// the compiled combination agrees with the decision.
+				holds, err := combined.EvaluateRow(tt.input, row)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if holds != allowed {
+					t.Errorf("row %v: combined rule got %t, Decide %t", row, holds, allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestDenyFailsClosed(t *testing.T) {
+	policy, err := ParseExpression(`deny @minors user.age:number < 18`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(policy); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	evaluated, err := policy.EvaluateInput(Input{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := constantValue(evaluated); !ok || !value || evaluated.Effect != EffectDeny {
+		t.Errorf("EvaluateInput got %+v, want a deny holding", evaluated)
+	}
+
+	holds, err := policy.EvaluateRow(Input{}, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !holds {
+		t.Error("EvaluateRow: a missing attribute must apply the deny")
+	}
+
+	compiled, errs := Compile(policy)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	result, err := compiled.Evaluate(Input{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := constantValue(result); !ok || !value || result.Effect != EffectDeny {
+		t.Errorf("CompiledPolicy.Evaluate got %+v, want a deny holding", result)
+	}
+
+	trace, err := policy.Explain(Input{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !trace.Denied || trace.Outcome != OutcomeTrue {
+		t.Errorf("Explain got denied %t outcome %s, want a deny holding", trace.Denied, trace.Outcome)
+	}
+}
+
+func TestCombiningAlgorithmByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    CombiningAlgorithm
+		wantErr bool
+	}{
+		{name: "", want: DenyOverrides},
+		{name: "deny-overrides", want: DenyOverrides},
+		{name: "permit-overrides", want: PermitOverrides},
+		{name: "first-applicable", want: FirstApplicable},
+		{name: "only-one-applicable", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := CombiningAlgorithmByName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %t", tt.name, err, tt.wantErr)
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateEffect(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *Rule
+		want int
+	}{
+		{
+			name: "deny",
+			rule: &Rule{Name: "r", Type: "bool", Operator: "true", Effect: EffectDeny},
+		},
+		{
+			name: "unknown effect",
+			rule: &Rule{Name: "r", Type: "bool", Operator: "true", Effect: "allow"},
+			want: 1,
+		},
+		{
+			name: "effect of an item",
+			rule: &Rule{Name: "r", Type: "group", Operator: "AND", Items: []Rule{
+				{Name: "item", Type: "bool", Operator: "true", Effect: EffectPermit},
+			}},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := Validate(tt.rule); len(errs) != tt.want {
+				t.Errorf("got %v, want %d errors", errs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package rulejson
+
+import (
+	"fmt"
+	"strings"
+)
+
+// comparisonOperators maps the operators of rules with type comparison
+// that compare single values to SQL. Besides them `in` holds if the first
+// attribute is one of the values of the second and `contains` if the first
+// attribute holds the value of the second.
+var comparisonOperators = map[string]string{
+	"equal":    "=",
+	"notEqual": "<>",
+	"lt":       "<",
+	"lte":      "<=",
+	"gt":       ">",
+	"gte":      ">=",
+}
+
+func validateComparison(rule *Rule, errs *[]RuleError) {
+	if _, ok := comparisonOperators[rule.Operator]; !ok && rule.Operator != "in" && rule.Operator != "contains" {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeComparisonOperatorUnknown,
+			Err:  "unknown operator `" + rule.Operator + "` for rule with type `comparison`",
+		})
+	}
+	if len(rule.Attributes) != 2 {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeComparisonAttributeCount,
+			Err:  "rule with type `comparison` must have exactly two attributes",
+		})
+
+		return
+	}
+	if rule.Attributes[0].Name == "" || rule.Attributes[1].Name == "" {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeAttributeMissing,
+			Err:  "rule with type `comparison` must have named attributes",
+		})
+	}
+	if kindOf(rule.Attributes[0]) != kindOf(rule.Attributes[1]) {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeComparisonKindMismatch,
+			Err:  "rule with type `comparison` must compare attributes of the same kind",
+		})
+	}
+	if rule.Assert != nil {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeAssertNotAllowed,
+			Err:  "rule with type `comparison` shouldn't have field `target` set",
+		})
+	}
+	if len(rule.Items) > 0 {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeItemsNotAllowed,
+			Err:  "rule with type `comparison` must have no child item",
+		})
+	}
+
+	// only the list side of in and contains may be a multi-valued column.
+	for i, attr := range rule.Attributes {
+		list := (rule.Operator == "in" && i == 1) || (rule.Operator == "contains" && i == 0)
+		if attr.Multi && !list {
+			*errs = append(*errs, RuleError{
+				Name: rule.Name,
+				Code: CodeMultiOperatorInvalid,
+				Err:  "multi-valued attribute `" + attr.Name + "` can't be compared with operator `" + rule.Operator + "`",
+			})
+		}
+	}
+}
+
+func kindOf(attr RuleAttribute) string {
+	if attr.Kind == "" {
+		return KindString
+	}
+
+	return attr.Kind
+}
+
+// evaluateComparison compares the values of two resolved attributes. Like
+// attributes, a multi-valued side holds if any of its values does, with
+// notEqual if none of them is equal.
+func evaluateComparison(operator string, left []string, right []string, kind string) bool {
+	return comparisonMatcher(operator, kind)(left, right)
+}
+
+// comparisonOrderings maps the comparison operators that hold for a pair
+// of values to the comparison between them.
+var comparisonOrderings = map[string]string{
+	"equal": "=",
+	"in":    "=",
+	"lt":    "<",
+	"lte":   "<=",
+	"gt":    ">",
+	"gte":   ">=",
+}
+
+// comparisonMatcher resolves operator into the function deciding the
+// values of both attributes, see evaluateComparison.
+func comparisonMatcher(operator string, kind string) func(left []string, right []string) bool {
+	switch operator {
+	case "contains":
+		in := comparisonMatcher("in", kind)
+
+		return func(left []string, right []string) bool { return in(right, left) }
+	case "notEqual":
+		equal := comparisonMatcher("equal", kind)
+
+		return func(left []string, right []string) bool { return !equal(left, right) }
+	}
+
+	holds, ok := orderings[comparisonOrderings[operator]]
+	if !ok {
+		return func([]string, []string) bool { return false }
+	}
+
+	return func(left []string, right []string) bool {
+		for _, l := range left {
+			for _, r := range right {
+				if c, ok := compareValues(l, r, kind); ok && holds(c) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+func sqlCompileComparison(b *SQLBuilder, rule *Rule) (string, error) {
+	if len(rule.Attributes) != 2 {
+		return "", fmt.Errorf("rule `%s` with type `comparison` must have exactly two attributes", rule.Name)
+	}
+
+	left, right := rule.Attributes[0], rule.Attributes[1]
+	operator := rule.Operator
+	if operator == "contains" {
+		left, right, operator = right, left, "in"
+	}
+
+	leftValues, leftResolved := rule.Resolved[left.Name]
+	rightValues, rightResolved := rule.Resolved[right.Name]
+	if leftResolved && rightResolved {
+		return b.dialect().Bool(evaluateComparison(operator, leftValues, rightValues, kindOf(left))), nil
+	}
+	if !leftResolved {
+		if _, err := dataColumn(left.Name); err != nil {
+			return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+		}
+	}
+	if !rightResolved {
+		if _, err := dataColumn(right.Name); err != nil {
+			return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+		}
+	}
+
+	if operator == "in" {
+		switch {
+		case right.Multi:
+			values := []string{b.ident(left.Name)}
+			if leftResolved {
+				values = b.bindAll(leftValues, left.Kind)
+			}
+			if len(values) == 0 {
+				return b.dialect().Bool(false), nil
+			}
+
+			return b.dialect().Overlaps(b.ident(right.Name), values), nil
+		case leftResolved:
+			// a single value column is in a list of values that holds it.
+			operator = "equal"
+		default:
+			return sqlCompileComparisonList(b, "IN", b.column(left, "IN"), rightValues, rightResolved, right)
+		}
+	}
+
+	op, ok := comparisonOperators[operator]
+	if !ok {
+		return "", fmt.Errorf("unknown operator `%s`", rule.Operator)
+	}
+	switch {
+	case leftResolved && len(leftValues) != 1:
+		return sqlCompileComparisonList(b, flipOperator(op), b.column(right, op), leftValues, true, left)
+	case rightResolved && len(rightValues) != 1:
+		return sqlCompileComparisonList(b, op, b.column(left, op), rightValues, true, right)
+	}
+
+	return fmt.Sprintf("%s %s %s", comparisonSide(b, op, left, leftValues, leftResolved), op,
+		comparisonSide(b, op, right, rightValues, rightResolved)), nil
+}
+
+// comparisonSide renders one side of a comparison, the bound value if the
+// attribute was resolved to a single value and the column otherwise.
+func comparisonSide(b *SQLBuilder, op string, attr RuleAttribute, values []string, resolved bool) string {
+	if resolved {
+		return b.bind(values[0], attr.Kind)
+	}
+
+	return b.column(attr, op)
+}
+
+// sqlCompileComparisonList compares expr against the values of a resolved
+// multi-valued attribute, or a column for IN with a single value column.
+func sqlCompileComparisonList(b *SQLBuilder, op string, expr string, values []string, resolved bool, attr RuleAttribute) (string, error) {
+	if !resolved {
+		return fmt.Sprintf("%s = %s", expr, b.ident(attr.Name)), nil
+	}
+	if len(values) == 0 {
+		return b.dialect().Bool(op == "<>"), nil
+	}
+
+	bound := b.bindAll(values, attr.Kind)
+	switch op {
+	case "=", "IN":
+		return fmt.Sprintf("%s IN (%s)", expr, strings.Join(bound, ", ")), nil
+	case "<>":
+		return fmt.Sprintf("%s NOT IN (%s)", expr, strings.Join(bound, ", ")), nil
+	}
+
+	conditions := make([]string, len(bound))
+	for i := range bound {
+		conditions[i] = fmt.Sprintf("%s %s %s", expr, op, bound[i])
+	}
+
+	return "( " + strings.Join(conditions, " OR ") + " )", nil
+}
+
+// flipOperator returns the operator that holds with swapped sides.
+func flipOperator(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+
+	return op
+}
@@ -0,0 +1,211 @@
+package rulejson
+
+import (
+	"reflect"
+	"testing"
+)
+
+func comparisonRule(operator string, left RuleAttribute, right RuleAttribute) *Rule {
+	return &Rule{
+		Name:       "compare",
+		Type:       "comparison",
+		Operator:   operator,
+		Attributes: []RuleAttribute{left, right},
+	}
+}
+
+func TestEvaluateComparison(t *testing.T) {
+	input := Input{
+		"user.level":   {"3"},
+		"user.max":     {"5"},
+		"user.city":    {"Hamburg"},
+		"user.cities":  {"Berlin", "Hamburg"},
+		"user.regions": {"eu"},
+	}
+	tests := []struct {
+		name     string
+		operator string
+		left     RuleAttribute
+		right    RuleAttribute
+		want     string
+	}{
+		{name: "lt", operator: "lt", left: RuleAttribute{Name: "user.level", Kind: KindNumber}, right: RuleAttribute{Name: "user.max", Kind: KindNumber}, want: "true"},
+		{name: "gte", operator: "gte", left: RuleAttribute{Name: "user.level", Kind: KindNumber}, right: RuleAttribute{Name: "user.max", Kind: KindNumber}, want: "false"},
+		{name: "equal", operator: "equal", left: RuleAttribute{Name: "user.city"}, right: RuleAttribute{Name: "user.city"}, want: "true"},
+		{name: "notEqual", operator: "notEqual", left: RuleAttribute{Name: "user.city"}, right: RuleAttribute{Name: "user.cities"}, want: "false"},
+		{name: "in", operator: "in", left: RuleAttribute{Name: "user.city"}, right: RuleAttribute{Name: "user.cities"}, want: "true"},
+		{name: "contains", operator: "contains", left: RuleAttribute{Name: "user.cities"}, right: RuleAttribute{Name: "user.regions"}, want: "false"},
+		{name: "unresolved", operator: "equal", left: RuleAttribute{Name: "data.city"}, right: RuleAttribute{Name: "user.city"}, want: `data.city = 'Hamburg'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := comparisonRule(tt.operator, tt.left, tt.right)
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			evaluated, err := rule.EvaluateInput(input)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			if evaluated.Stringer() != tt.want {
+				t.Errorf("EvaluateInput() got = >%v<, want >%v<", evaluated.Stringer(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLComparison(t *testing.T) {
+	input := Input{
+		"user.level":  {"3"},
+		"user.cities": {"Berlin", "Hamburg"},
+		"user.none":   {},
+	}
+	tests := []struct {
+		name      string
+		operator  string
+		left      RuleAttribute
+		right     RuleAttribute
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "data vs data",
+			operator:  "lte",
+			left:      RuleAttribute{Name: "data.created", Kind: KindDate},
+			right:     RuleAttribute{Name: "data.published", Kind: KindDate},
+			wantWhere: `"created" <= "published"`,
+		},
+		{
+			name:      "resolved left side",
+			operator:  "gt",
+			left:      RuleAttribute{Name: "user.level", Kind: KindNumber},
+			right:     RuleAttribute{Name: "data.level", Kind: KindNumber},
+			wantWhere: `$1 > "level"`,
+			wantArgs:  []any{"3"},
+		},
+		{
+			name:      "in list of user values",
+			operator:  "in",
+			left:      RuleAttribute{Name: "data.city"},
+			right:     RuleAttribute{Name: "user.cities"},
+			wantWhere: `"city" IN ($1, $2)`,
+			wantArgs:  []any{"Berlin", "Hamburg"},
+		},
+		{
+			name:      "notEqual list of user values",
+			operator:  "notEqual",
+			left:      RuleAttribute{Name: "user.cities"},
+			right:     RuleAttribute{Name: "data.city"},
+			wantWhere: `"city" NOT IN ($1, $2)`,
+			wantArgs:  []any{"Berlin", "Hamburg"},
+		},
+		{
+			name:      "lt any of user values",
+			operator:  "lt",
+			left:      RuleAttribute{Name: "user.cities"},
+			right:     RuleAttribute{Name: "data.city"},
+			wantWhere: `( "city" COLLATE "C" > $1 OR "city" COLLATE "C" > $2 )`,
+			wantArgs:  []any{"Berlin", "Hamburg"},
+		},
+		{
+			name:      "in multi-valued column",
+			operator:  "in",
+			left:      RuleAttribute{Name: "user.cities"},
+			right:     RuleAttribute{Name: "data.cities", Multi: true},
+			wantWhere: `( $1 = ANY("cities") OR $2 = ANY("cities") )`,
+			wantArgs:  []any{"Berlin", "Hamburg"},
+		},
+		{
+			name:      "data column contains data column",
+			operator:  "contains",
+			left:      RuleAttribute{Name: "data.cities", Multi: true},
+			right:     RuleAttribute{Name: "data.city"},
+			wantWhere: `"city" = ANY("cities")`,
+		},
+		{
+			name:      "contains user value",
+			operator:  "contains",
+			left:      RuleAttribute{Name: "user.cities"},
+			right:     RuleAttribute{Name: "data.city"},
+			wantWhere: `"city" IN ($1, $2)`,
+			wantArgs:  []any{"Berlin", "Hamburg"},
+		},
+		{
+			name:      "in no values",
+			operator:  "in",
+			left:      RuleAttribute{Name: "data.city"},
+			right:     RuleAttribute{Name: "user.none"},
+			wantWhere: `FALSE`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := comparisonRule(tt.operator, tt.left, tt.right)
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			evaluated, err := rule.EvaluateInput(input)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			b := &SQLBuilder{}
+			where, err := b.Compile(evaluated)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), tt.wantArgs) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestValidateComparison(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *Rule
+		want []RuleError
+	}{
+		{
+			name: "missing attribute",
+			rule: &Rule{Name: "compare", Type: "comparison", Operator: "equal", Attributes: []RuleAttribute{{Name: "data.city"}}},
+			want: []RuleError{{Name: "compare", Code: CodeComparisonAttributeCount, Err: "rule with type `comparison` must have exactly two attributes"}},
+		},
+		{
+			name: "unknown operator",
+			rule: comparisonRule("like", RuleAttribute{Name: "data.city"}, RuleAttribute{Name: "user.city"}),
+			want: []RuleError{{Name: "compare", Code: CodeComparisonOperatorUnknown, Err: "unknown operator `like` for rule with type `comparison`"}},
+		},
+		{
+			name: "different kinds",
+			rule: comparisonRule("equal", RuleAttribute{Name: "data.level", Kind: KindNumber}, RuleAttribute{Name: "user.level"}),
+			want: []RuleError{{Name: "compare", Code: CodeComparisonKindMismatch, Err: "rule with type `comparison` must compare attributes of the same kind"}},
+		},
+		{
+			name: "multi-valued scalar side",
+			rule: comparisonRule("equal", RuleAttribute{Name: "data.tags", Multi: true}, RuleAttribute{Name: "user.tag"}),
+			want: []RuleError{{Name: "compare", Code: CodeMultiOperatorInvalid, Err: "multi-valued attribute `data.tags` can't be compared with operator `equal`"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Validate(tt.rule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileMalformedComparison(t *testing.T) {
+	rule := &Rule{Name: "compare", Type: "comparison", Operator: "equal", Attributes: []RuleAttribute{{Name: "data.city"}}}
+	evaluated, err := rule.EvaluateInput(Input{})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+	if _, err := (&SQLBuilder{}).Compile(evaluated); err == nil {
+		t.Errorf("Compile() expected error for a comparison with one attribute")
+	}
+}
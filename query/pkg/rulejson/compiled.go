@@ -0,0 +1,263 @@
+package rulejson
+
+import (
+	"time"
+)
+
+// CompiledPolicy is a validated rule prepared for evaluation. Asserts are
+// parsed and operators resolved once by Compile, evaluating only walks a
+// tree of typed nodes. A CompiledPolicy is immutable and safe for
+// concurrent use.
+type CompiledPolicy struct {
+	root      compiledNode
+	effect    string
+	trueRule  *Rule
+	falseRule *Rule
+}
+
+// Compile validates a copy of rule and prepares it for evaluation, rule
+// itself is not modified.
+func Compile(rule *Rule) (*CompiledPolicy, []RuleError) {
+	cop := &Rule{}
+	cloneRule(rule, cop)
+	if errs := Validate(cop); len(errs) != 0 {
+		return nil, errs
+	}
+
+	p := &CompiledPolicy{
+		root:      compileNode(cop),
+		effect:    cop.Effect,
+		trueRule:  constantRule(cop.Name, true),
+		falseRule: constantRule(cop.Name, false),
+	}
+	p.trueRule.Effect, p.falseRule.Effect = cop.Effect, cop.Effect
+
+	return p, nil
+}
+
+// Evaluate decides the policy for input like Rule.EvaluateInput. Unlike
+// it, items decided without deciding their group are dropped from the
+// result instead of being kept as true or false. The returned rule shares
+// its parts with the policy and other results and must not be modified.
+func (p *CompiledPolicy) Evaluate(input Input, opts ...EvaluateOption) (*Rule, error) {
+	ev := newEvaluation(input, p.effect, opts)
+
+	result, residual, err := p.root.evaluate(ev)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case result == False:
+		return p.falseRule, nil
+	case result == True:
+		return p.trueRule, nil
+	case residual.Effect != p.effect:
+		// the residual is a part of the policy, the effect is the root's.
+		root := *residual
+		root.Effect = p.effect
+
+		return &root, nil
+	}
+
+	return residual, nil
+}
+
+// compiledNode evaluates a part of a policy, the residual rule is only set
+// if it is undecided.
+type compiledNode interface {
+	evaluate(ev *evaluation) (Decision, *Rule, error)
+}
+
+func compileNode(rule *Rule) compiledNode {
+	switch rule.Type {
+	case "bool":
+		return constNode{value: decisionFor(rule.Operator == "true")}
+	case "attribute":
+		// the rules of leaves are returned as residuals.
+		rule.Decision = Residual
+
+		return &attributeNode{
+			rule:  rule,
+			match: valuesMatcher(rule.Operator, rule.ParsedTarget, rule.Attribute.Kind),
+		}
+	case "comparison":
+		rule.Decision = Residual
+
+		return &comparisonNode{
+			rule:  rule,
+			match: comparisonMatcher(rule.Operator, kindOf(rule.Attributes[0])),
+		}
+	}
+
+	// groups are returned as residuals if none of their items is decided
+	// or changed.
+	rule.Decision = Residual
+	items := make([]compiledNode, len(rule.Items))
+	for i := range rule.Items {
+		items[i] = compileNode(&rule.Items[i])
+	}
+	if rule.Operator == "NOT" {
+		return &notNode{rule: rule, item: items[0]}
+	}
+
+	return &groupNode{rule: rule, and: rule.Operator == "AND", items: items}
+}
+
+type constNode struct {
+	value Decision
+}
+
+func (n constNode) evaluate(*evaluation) (Decision, *Rule, error) {
+	return n.value, nil, nil
+}
+
+type attributeNode struct {
+	// rule is the residual if the data attribute is not part of the
+	// input.
+	rule  *Rule
+	match func(values []string, now time.Time) bool
+}
+
+func (n *attributeNode) evaluate(ev *evaluation) (Decision, *Rule, error) {
+	values, ok, decided, err := ev.resolve(n.rule, n.rule.Attribute.Name)
+	switch {
+	case err != nil:
+		return Unevaluated, nil, err
+	case decided.Decided():
+		return decided, nil, nil
+	case !ok:
+		return Residual, n.rule, nil
+	}
+
+	return decisionFor(n.match(values, ev.now)), nil, nil
+}
+
+type comparisonNode struct {
+	// rule is the residual if neither attribute is part of the input.
+	rule  *Rule
+	match func(left []string, right []string) bool
+}
+
+func (n *comparisonNode) evaluate(ev *evaluation) (Decision, *Rule, error) {
+	left, right := n.rule.Attributes[0], n.rule.Attributes[1]
+	leftValues, leftOK, decided, err := ev.resolve(n.rule, left.Name)
+	if err != nil || decided.Decided() {
+		return decided, nil, err
+	}
+	rightValues, rightOK, decided, err := ev.resolve(n.rule, right.Name)
+	if err != nil || decided.Decided() {
+		return decided, nil, err
+	}
+
+	switch {
+	case leftOK && rightOK:
+		return decisionFor(n.match(leftValues, rightValues)), nil, nil
+	case !leftOK && !rightOK:
+		return Residual, n.rule, nil
+	}
+
+	residual := *n.rule
+	if leftOK {
+		residual.Resolved = ev.resolvedOf(left.Name, leftValues)
+	} else {
+		residual.Resolved = ev.resolvedOf(right.Name, rightValues)
+	}
+
+	return Residual, &residual, nil
+}
+
+// resolvedOf returns the Resolved map of a comparison with the input
+// attribute name, the residuals of an evaluation share it.
+func (ev *evaluation) resolvedOf(name string, values []string) map[string][]string {
+	if resolved, ok := ev.resolved[name]; ok {
+		return resolved
+	}
+	if ev.resolved == nil {
+		ev.resolved = map[string]map[string][]string{}
+	}
+	ev.resolved[name] = map[string][]string{name: values}
+
+	return ev.resolved[name]
+}
+
+type notNode struct {
+	rule *Rule
+	item compiledNode
+}
+
+func (n *notNode) evaluate(ev *evaluation) (Decision, *Rule, error) {
+	ev.missingValue = !ev.missingValue
+	result, residual, err := n.item.evaluate(ev)
+	ev.missingValue = !ev.missingValue
+	switch {
+	case err != nil:
+		return Unevaluated, nil, err
+	case result == True:
+		return False, nil, nil
+	case result == False:
+		return True, nil, nil
+	case residual == &n.rule.Items[0]:
+		return Residual, n.rule, nil
+	}
+
+	return Residual, &Rule{
+		Name:     n.rule.Name,
+		Type:     "group",
+		Operator: "NOT",
+		Items:    []Rule{*residual},
+		Decision: Residual,
+	}, nil
+}
+
+type groupNode struct {
+	rule  *Rule
+	and   bool
+	items []compiledNode
+}
+
+func (n *groupNode) evaluate(ev *evaluation) (Decision, *Rule, error) {
+	decisive := decisionFor(!n.and)
+
+	// residuals points to the undecided items, they are only copied into a
+	// new group if the group itself can't be shared.
+	var buf [8]*Rule
+	residuals := buf[:0]
+	shared := true
+	for i, item := range n.items {
+		result, residual, err := item.evaluate(ev)
+		switch {
+		case err != nil:
+			return Unevaluated, nil, err
+		case result == decisive:
+			return decisive, nil, nil
+		case result.Decided():
+			shared = false
+		default:
+			residuals = append(residuals, residual)
+			shared = shared && residual == &n.rule.Items[i]
+		}
+	}
+
+	switch {
+	case len(residuals) == 0:
+		return decisionFor(n.and), nil, nil
+	case len(residuals) == 1:
+		return Residual, residuals[0], nil
+	case shared:
+		return Residual, n.rule, nil
+	}
+
+	items := make([]Rule, len(residuals))
+	for i := range residuals {
+		items[i] = *residuals[i]
+	}
+
+	return Residual, &Rule{
+		Name:     n.rule.Name,
+		Type:     "group",
+		Operator: n.rule.Operator,
+		Items:    items,
+		Decision: Residual,
+	}, nil
+}
@@ -0,0 +1,407 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+const compiledPolicy = `{
+	"name": "root",
+	"type": "group",
+	"operator": "OR",
+	"items": [
+		{
+			"name": "admins",
+			"type": "attribute",
+			"attribute": {"name": "user.role"},
+			"operator": "equal",
+			"assert": {"value": "admin"}
+		},
+		{
+			"name": "own department",
+			"type": "group",
+			"operator": "AND",
+			"items": [
+				{
+					"name": "department",
+					"type": "comparison",
+					"operator": "equal",
+					"attributes": [{"name": "data.department"}, {"name": "user.department"}]
+				},
+				{
+					"name": "public",
+					"type": "attribute",
+					"attribute": {"name": "data.public"},
+					"operator": "equal",
+					"assert": {"value": "yes"}
+				},
+				{
+					"name": "not archived",
+					"type": "group",
+					"operator": "NOT",
+					"items": [
+						{
+							"name": "archived",
+							"type": "attribute",
+							"attribute": {"name": "data.archived"},
+							"operator": "equal",
+							"assert": {"value": "yes"}
+						}
+					]
+				}
+			]
+		},
+		{
+			"name": "level",
+			"type": "attribute",
+			"attribute": {"name": "user.level", "kind": "number"},
+			"operator": "greaterThan",
+			"assert": {"value": "4"}
+		}
+	]
+}`
+
+func compiledTestRule(t testing.TB) *Rule {
+	rule := &Rule{}
+	if err := json.Unmarshal([]byte(compiledPolicy), rule); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+
+	return rule
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      Input
+		opts       []EvaluateOption
+		wantString string
+		wantErr    bool
+	}{
+		{
+			name:       "admin",
+			input:      Input{"user.role": {"admin"}, "user.level": {"1"}},
+			wantString: `true`,
+		},
+		{
+			name:       "level",
+			input:      Input{"user.role": {"staff"}, "user.level": {"7"}, "user.department": {"sales"}},
+			wantString: `true`,
+		},
+		{
+			name:       "residual",
+			input:      Input{"user.role": {"staff"}, "user.level": {"1"}, "user.department": {"sales"}},
+			wantString: `( data.department = 'sales' AND data.public = 'yes' AND ( NOT data.archived = 'yes' ) )`,
+		},
+		{
+			name:       "missing department",
+			input:      Input{"user.role": {"staff"}, "user.level": {"1"}},
+			wantString: `false`,
+		},
+		{
+			name:       "missing department null",
+			input:      Input{"user.role": {"staff"}, "user.level": {"1"}},
+			opts:       []EvaluateOption{WithMissing(MissingNull)},
+			wantString: `false`,
+		},
+		{
+			name:    "missing department error",
+			input:   Input{"user.role": {"staff"}, "user.level": {"1"}},
+			opts:    []EvaluateOption{WithMissing(MissingError)},
+			wantErr: true,
+		},
+	}
+
+	policy, errs := Compile(compiledTestRule(t))
+	if errs != nil {
+		t.Fatalf("Compile() errors = %v", errs)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policy.Evaluate(tt.input, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Stringer() != tt.wantString {
+				t.Errorf("Evaluate() got = >%v<, want >%v<", got.Stringer(), tt.wantString)
+			}
+
+			// the residual compiles to the same SQL as the simplified result
+			// of EvaluateInput.
+			rule := compiledTestRule(t)
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			evaluated, err := rule.EvaluateInput(tt.input, tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			wantSQL, err := (&SQLBuilder{}).Compile(evaluated.Simplify())
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			gotSQL, err := (&SQLBuilder{}).Compile(got)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if gotSQL != wantSQL {
+				t.Errorf("Compile() got = >%v<, want >%v<", gotSQL, wantSQL)
+			}
+		})
+	}
+}
+
+func TestCompileDoesNotModifyRule(t *testing.T) {
+	rule := compiledTestRule(t)
+	rule.Items[0].Name = ""
+	if _, errs := Compile(rule); errs != nil {
+		t.Fatalf("Compile() errors = %v", errs)
+	}
+	if rule.Items[0].Name != "" || rule.Items[0].ParsedTarget != nil {
+		t.Errorf("Compile() modified the rule: name = %v, parsed target = %v", rule.Items[0].Name, rule.Items[0].ParsedTarget)
+	}
+}
+
+func TestCompileKinds(t *testing.T) {
+	rule := &Rule{}
+	if err := json.Unmarshal([]byte(benchmarkPolicy), rule); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+	policy, errs := Compile(rule)
+	if errs != nil {
+		t.Fatalf("Compile() errors = %v", errs)
+	}
+
+	tests := []struct {
+		name       string
+		input      map[string]string
+		wantString string
+	}{
+		{
+			name:       "residual",
+			input:      benchmarkInput,
+			wantString: `( data.department = 'sales' AND data.amount BETWEEN 0 AND 10000 AND data.created >= (now() - INTERVAL 'P30D') )`,
+		},
+		{
+			name:       "wildcard",
+			input:      map[string]string{"user.role": "staff", "user.email": "ann@example.org", "user.hired": "2019-05-01", "user.level": "2"},
+			wantString: `false`,
+		},
+		{
+			name:       "date",
+			input:      map[string]string{"user.role": "staff", "user.email": "ann@example.com", "user.hired": "2024-05-01", "user.level": "2"},
+			wantString: `false`,
+		},
+		{
+			name:       "number",
+			input:      map[string]string{"user.role": "staff", "user.email": "ann@example.org", "user.hired": "2019-05-01", "user.level": "10"},
+			wantString: `true`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policy.Evaluate(singleValued(tt.input), WithClock(testClock))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got.Stringer() != tt.wantString {
+				t.Errorf("Evaluate() got = >%v<, want >%v<", got.Stringer(), tt.wantString)
+			}
+		})
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	rule := &Rule{
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "city"},
+	}
+	policy, errs := Compile(rule)
+	if policy != nil || len(errs) == 0 {
+		t.Errorf("Compile() got = %v, %v, want nil and errors", policy, errs)
+	}
+}
+
+func TestCompiledPolicyConcurrent(t *testing.T) {
+	policy, errs := Compile(compiledTestRule(t))
+	if errs != nil {
+		t.Fatalf("Compile() errors = %v", errs)
+	}
+
+	want := `( data.department = 'sales' AND data.public = 'yes' AND ( NOT data.archived = 'yes' ) )`
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				input := Input{"user.role": {"staff"}, "user.level": {"1"}, "user.department": {"sales"}}
+				if i%2 == 1 {
+					input["user.level"] = []string{"9"}
+				}
+				got, err := policy.Evaluate(input)
+				if err != nil {
+					t.Errorf("Evaluate() error = %v", err)
+
+					return
+				}
+				if i%2 == 1 && got.Stringer() != "true" {
+					t.Errorf("Evaluate() got = >%v<, want >true<", got.Stringer())
+				}
+				if i%2 == 0 && got.Stringer() != want {
+					t.Errorf("Evaluate() got = >%v<, want >%v<", got.Stringer(), want)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// benchmarkPolicy mixes the assert kinds policies use: strings, numbers,
+// dates, relative times and wildcards, decided by user attributes or left
+// for SQL.
+const benchmarkPolicy = `{
+	"type": "group",
+	"operator": "OR",
+	"items": [
+		{
+			"name": "admins",
+			"type": "attribute",
+			"attribute": {"name": "user.role"},
+			"operator": "in",
+			"assert": {"values": ["admin", "auditor"]}
+		},
+		{
+			"name": "staff",
+			"type": "group",
+			"operator": "AND",
+			"items": [
+				{
+					"name": "company mail",
+					"type": "attribute",
+					"attribute": {"name": "user.email"},
+					"operator": "matchesWildcard",
+					"assert": {"value": "*@example.com", "ignoreCase": true}
+				},
+				{
+					"name": "hired",
+					"type": "attribute",
+					"attribute": {"name": "user.hired", "kind": "date"},
+					"operator": "lessThan",
+					"assert": {"value": "2024-01-01"}
+				},
+				{
+					"name": "department",
+					"type": "comparison",
+					"operator": "equal",
+					"attributes": [{"name": "data.department"}, {"name": "user.department"}]
+				},
+				{
+					"name": "amount",
+					"type": "attribute",
+					"attribute": {"name": "data.amount", "kind": "number"},
+					"operator": "range",
+					"assert": {"from": "0", "to": "10000"}
+				},
+				{
+					"name": "recent",
+					"type": "attribute",
+					"attribute": {"name": "data.created", "kind": "datetime"},
+					"operator": "withinLast",
+					"assert": {"duration": "P30D"}
+				}
+			]
+		},
+		{
+			"name": "level",
+			"type": "attribute",
+			"attribute": {"name": "user.level", "kind": "number"},
+			"operator": "greaterThan",
+			"assert": {"value": "4"}
+		}
+	]
+}`
+
+// benchmarkPolicies is a policy set of n copies of benchmarkPolicy, as
+// evaluated per request.
+func benchmarkPolicies(b *testing.B, n int) *Rule {
+	set := &Rule{Name: "policies", Type: "group", Operator: "AND"}
+	for i := 0; i < n; i++ {
+		rule := &Rule{}
+		if err := json.Unmarshal([]byte(benchmarkPolicy), rule); err != nil {
+			b.Fatalf("failed to unmarshal json: %v", err)
+		}
+		rule.Name = fmt.Sprintf("policy %d", i)
+		set.Items = append(set.Items, *rule)
+	}
+
+	return set
+}
+
+var benchmarkInput = map[string]string{
+	"user.role":       "staff",
+	"user.email":      "Ann@Example.com",
+	"user.hired":      "2019-05-01",
+	"user.department": "sales",
+	"user.level":      "2",
+}
+
+// BenchmarkEvaluate is the work of a request without Compile: evaluating
+// the validated policies and compiling the residual to SQL.
+func BenchmarkEvaluate(b *testing.B) {
+	rule := benchmarkPolicies(b, 100)
+	if err := Validate(rule); err != nil {
+		b.Fatalf("failed to validate rule: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		evaluated, err := rule.Evaluate(benchmarkInput, WithClock(testClock))
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = evaluated.Stringer()
+		if _, err := (&SQLBuilder{}).Compile(evaluated); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCompiledPolicy does the work of BenchmarkEvaluate with a
+// CompiledPolicy.
+func BenchmarkCompiledPolicy(b *testing.B) {
+	policy, errs := Compile(benchmarkPolicies(b, 100))
+	if errs != nil {
+		b.Fatalf("Compile() errors = %v", errs)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		evaluated, err := policy.Evaluate(singleValued(benchmarkInput), WithClock(testClock))
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = evaluated.Stringer()
+		if _, err := (&SQLBuilder{}).Compile(evaluated); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// singleValued converts input like Rule.Evaluate does.
+func singleValued(input map[string]string) Input {
+	multi := make(Input, len(input))
+	for name, value := range input {
+		multi[name] = []string{value}
+	}
+
+	return multi
+}
@@ -0,0 +1,539 @@
+package rulejson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// likeEscape is the escape character declared in every LIKE clause
+// compiled by rulejson. It is not special in string literals of any
+// supported database, unlike the backslash in MySQL.
+const likeEscape = "!"
+
+// Dialect owns the parts of the generated SQL that differ between
+// databases.
+type Dialect interface {
+	// Name returns the identifier the dialect is selected by.
+	Name() string
+	// QuoteIdent quotes a column reference, dotted names are quoted per part.
+	QuoteIdent(name string) string
+	// QuoteLiteral renders value as a string literal.
+	QuoteLiteral(value string) string
+	// Value renders a value of the given attribute kind. bind adds an
+	// argument and returns its placeholder, without bind the value is
+	// inlined as a literal.
+	Value(kind string, value string, bind func(any) string) string
+	// Collate renders the string expression expr with a binary collation,
+	// so it compares like in evaluation instead of by the collation of the
+	// column. order is set if the comparison orders, not only equates,
+	// strings.
+	Collate(expr string, order bool) string
+	// EscapeLike escapes value so a LIKE pattern matches it literally when
+	// compiled with ESCAPE '!'.
+	EscapeLike(value string) string
+	// Bool renders a boolean constant usable as a condition.
+	Bool(value bool) string
+	// Placeholder returns the placeholder for the n-th argument, starting at 1.
+	Placeholder(n int) string
+	// Position renders a condition that is true if needle is contained in
+	// haystack, an empty needle is contained in every string.
+	Position(needle string, haystack string) string
+	// LikePattern translates w into the pattern syntax expected by Like.
+	LikePattern(w Wildcard, ignoreCase bool) string
+	// Like renders a condition that is true if expr matches pattern, an
+	// expression evaluating to the result of LikePattern.
+	Like(expr string, pattern string, ignoreCase bool) string
+	// Now renders the current time of the database shifted by offset, for
+	// kind date the UTC day of it.
+	Now(offset time.Duration, kind string, bind func(any) string) string
+	// Overlaps renders a condition that is true if the list column expr
+	// holds any of values, rendered values of the column's kind.
+	Overlaps(expr string, values []string) string
+	// ContainsAll renders a condition that is true if the list column expr
+	// holds all of values.
+	ContainsAll(expr string, values []string) string
+	// Not negates the condition expr. Unlike SQL NOT, an unknown (NULL)
+	// condition negates to true, just like a rule that doesn't hold in
+	// memory.
+	Not(expr string) string
+}
+
+var (
+	Postgres  Dialect = postgresDialect{}
+	MySQL     Dialect = mysqlDialect{}
+	SQLite    Dialect = sqliteDialect{}
+	SQLServer Dialect = sqlServerDialect{}
+)
+
+// DialectByName returns the dialect registered under name. An empty name
+// selects Postgres.
+func DialectByName(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "", "postgres", "postgresql":
+		return Postgres, nil
+	case "mysql", "mariadb":
+		return MySQL, nil
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	case "sqlserver", "mssql":
+		return SQLServer, nil
+	}
+
+	return nil, fmt.Errorf("unknown sql dialect `%s`", name)
+}
+
+func quoteIdentParts(name string, open string, close string) string {
+	parts := strings.Split(name, ".")
+	for i := range parts {
+		parts[i] = open + strings.ReplaceAll(parts[i], close, close+close) + close
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func quoteANSILiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// plainValue binds value as is, or inlines it as a string literal or, for
+// kind number, a numeric literal.
+func plainValue(d Dialect, kind string, value string, bind func(any) string) string {
+	if bind != nil {
+		return bind(value)
+	}
+	if kind != KindNumber {
+		return d.QuoteLiteral(value)
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+
+	return d.QuoteLiteral(value)
+}
+
+// castValue renders value converted to the SQL type typ.
+func castValue(d Dialect, typ string, value string, bind func(any) string) string {
+	return "CAST(" + plainValue(d, KindString, value, bind) + " AS " + typ + ")"
+}
+
+// durationSeconds renders a duration as a number of seconds, for databases
+// without an interval type.
+func durationSeconds(d Dialect, value string, bind func(any) string) string {
+	duration, err := ParseDuration(value)
+	if err != nil {
+		return plainValue(d, KindString, value, bind)
+	}
+
+	return plainValue(d, KindNumber, strconv.FormatFloat(duration.Seconds(), 'f', -1, 64), bind)
+}
+
+// containsEach implements ContainsAll for dialects without a containment
+// operator as one Overlaps per value.
+func containsEach(d Dialect, expr string, values []string) string {
+	conditions := make([]string, len(values))
+	for i := range values {
+		conditions[i] = d.Overlaps(expr, values[i:i+1])
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+
+	return "( " + strings.Join(conditions, " AND ") + " )"
+}
+
+var likeReplacer = strings.NewReplacer(
+	likeEscape, likeEscape+likeEscape,
+	"%", likeEscape+"%",
+	"_", likeEscape+"_",
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) QuoteIdent(name string) string {
+	return quoteIdentParts(name, `"`, `"`)
+}
+
+func (postgresDialect) QuoteLiteral(value string) string {
+	return quoteANSILiteral(value)
+}
+
+var postgresTypes = map[string]string{
+	KindDate:     "DATE",
+	KindDateTime: "TIMESTAMPTZ",
+	KindDuration: "INTERVAL",
+}
+
+func (d postgresDialect) Value(kind string, value string, bind func(any) string) string {
+	typ, ok := postgresTypes[kind]
+	if !ok {
+		return plainValue(d, kind, value, bind)
+	}
+	if bind == nil {
+		return typ + " " + d.QuoteLiteral(value)
+	}
+
+	return castValue(d, typ, value, bind)
+}
+
+func (d postgresDialect) Now(offset time.Duration, kind string, bind func(any) string) string {
+	now := "now()"
+	if offset > 0 {
+		now = "(now() + " + d.Value(KindDuration, formatDuration(offset), bind) + ")"
+	}
+	if offset < 0 {
+		now = "(now() - " + d.Value(KindDuration, formatDuration(-offset), bind) + ")"
+	}
+	if kind == KindDate {
+		return "CAST(" + now + " AT TIME ZONE 'UTC' AS DATE)"
+	}
+
+	return now
+}
+
+func (postgresDialect) EscapeLike(value string) string {
+	return likeReplacer.Replace(value)
+}
+
+func (postgresDialect) Bool(value bool) string {
+	return strings.ToUpper(strconv.FormatBool(value))
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) Collate(expr string, order bool) string {
+	// deterministic collations only equate identical strings.
+	if !order {
+		return expr
+	}
+
+	return expr + ` COLLATE "C"`
+}
+
+func (postgresDialect) Position(needle string, haystack string) string {
+	return fmt.Sprintf("position(%s IN %s) > 0", needle, haystack)
+}
+
+func (d postgresDialect) LikePattern(w Wildcard, _ bool) string {
+	return w.likePattern(d.EscapeLike)
+}
+
+func (postgresDialect) Like(expr string, pattern string, ignoreCase bool) string {
+	if ignoreCase {
+		return fmt.Sprintf("%s ILIKE %s ESCAPE '%s'", expr, pattern, likeEscape)
+	}
+
+	return fmt.Sprintf("%s LIKE %s ESCAPE '%s'", expr, pattern, likeEscape)
+}
+
+func (postgresDialect) Not(expr string) string {
+	return fmt.Sprintf("(%s) IS NOT TRUE", expr)
+}
+
+// Postgres list columns are arrays. The values are compared with the
+// elements one by one, && and @> would need an array of the element type
+// of the column while bound values form a text[].
+func (postgresDialect) Overlaps(expr string, values []string) string {
+	conditions := make([]string, len(values))
+	for i := range values {
+		conditions[i] = fmt.Sprintf("%s = ANY(%s)", values[i], expr)
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+
+	return "( " + strings.Join(conditions, " OR ") + " )"
+}
+
+func (d postgresDialect) ContainsAll(expr string, values []string) string {
+	return containsEach(d, expr, values)
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string { return "mysql" }
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return quoteIdentParts(name, "`", "`")
+}
+
+func (mysqlDialect) QuoteLiteral(value string) string {
+	// backslash is an escape character in MySQL string literals unless
+	// NO_BACKSLASH_ESCAPES is set.
+	return quoteANSILiteral(strings.ReplaceAll(value, `\`, `\\`))
+}
+
+func (d mysqlDialect) Value(kind string, value string, bind func(any) string) string {
+	switch kind {
+	case KindDate:
+		return castValue(d, "DATE", value, bind)
+	case KindDateTime:
+		// DATETIME has no offset, values are compared in UTC.
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return plainValue(d, KindString, value, bind)
+		}
+
+		return castValue(d, "DATETIME(6)", t.UTC().Format("2006-01-02 15:04:05.999999"), bind)
+	case KindDuration:
+		return durationSeconds(d, value, bind)
+	}
+
+	return plainValue(d, kind, value, bind)
+}
+
+func (d mysqlDialect) Now(offset time.Duration, kind string, bind func(any) string) string {
+	now := "UTC_TIMESTAMP(6)"
+	if offset > 0 {
+		now = "(UTC_TIMESTAMP(6) + INTERVAL " + d.Value(KindDuration, formatDuration(offset), bind) + " SECOND)"
+	}
+	if offset < 0 {
+		now = "(UTC_TIMESTAMP(6) - INTERVAL " + d.Value(KindDuration, formatDuration(-offset), bind) + " SECOND)"
+	}
+	if kind == KindDate {
+		return "DATE(" + now + ")"
+	}
+
+	return now
+}
+
+func (mysqlDialect) EscapeLike(value string) string {
+	return likeReplacer.Replace(value)
+}
+
+func (mysqlDialect) Bool(value bool) string {
+	return strings.ToUpper(strconv.FormatBool(value))
+}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) Collate(expr string, _ bool) string {
+	return expr + " COLLATE utf8mb4_bin"
+}
+
+func (mysqlDialect) Position(needle string, haystack string) string {
+	return fmt.Sprintf("LOCATE(%s, %s) > 0", needle, haystack)
+}
+
+func (d mysqlDialect) LikePattern(w Wildcard, _ bool) string {
+	return w.likePattern(d.EscapeLike)
+}
+
+func (mysqlDialect) Like(expr string, pattern string, ignoreCase bool) string {
+	// the outcome of a plain LIKE depends on the column collation. A binary
+	// string would match bytes, utf8mb4_bin matches characters, _ consumes
+	// one character like ? in memory.
+	if ignoreCase {
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s) ESCAPE '%s'", expr, pattern, likeEscape)
+	}
+
+	return fmt.Sprintf("%s LIKE %s COLLATE utf8mb4_bin ESCAPE '%s'", expr, pattern, likeEscape)
+}
+
+func (mysqlDialect) Not(expr string) string {
+	return fmt.Sprintf("(%s) IS NOT TRUE", expr)
+}
+
+// MySQL list columns are JSON arrays.
+func (mysqlDialect) Overlaps(expr string, values []string) string {
+	return fmt.Sprintf("JSON_OVERLAPS(%s, JSON_ARRAY(%s))", expr, strings.Join(values, ", "))
+}
+
+func (mysqlDialect) ContainsAll(expr string, values []string) string {
+	return fmt.Sprintf("JSON_CONTAINS(%s, JSON_ARRAY(%s))", expr, strings.Join(values, ", "))
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) QuoteIdent(name string) string {
+	return quoteIdentParts(name, `"`, `"`)
+}
+
+func (sqliteDialect) QuoteLiteral(value string) string {
+	return quoteANSILiteral(value)
+}
+
+func (d sqliteDialect) Value(kind string, value string, bind func(any) string) string {
+	// SQLite has no date types, the date functions normalize text to the
+	// format they store, datetimes in UTC.
+	switch kind {
+	case KindDate:
+		return "date(" + plainValue(d, KindString, value, bind) + ")"
+	case KindDateTime:
+		return "datetime(" + plainValue(d, KindString, value, bind) + ")"
+	case KindDuration:
+		return durationSeconds(d, value, bind)
+	}
+
+	return plainValue(d, kind, value, bind)
+}
+
+func (d sqliteDialect) Now(offset time.Duration, kind string, bind func(any) string) string {
+	fn := "datetime"
+	if kind == KindDate {
+		fn = "date"
+	}
+	if offset == 0 {
+		return fn + "('now')"
+	}
+	modifier := strconv.FormatFloat(offset.Seconds(), 'f', -1, 64) + " seconds"
+
+	return fn + "('now', " + plainValue(d, KindString, modifier, bind) + ")"
+}
+
+func (sqliteDialect) EscapeLike(value string) string {
+	return likeReplacer.Replace(value)
+}
+
+func (sqliteDialect) Bool(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (sqliteDialect) Collate(expr string, _ bool) string {
+	// BINARY is the default collation.
+	return expr
+}
+
+func (sqliteDialect) Position(needle string, haystack string) string {
+	return fmt.Sprintf("instr(%s, %s) > 0", haystack, needle)
+}
+
+func (d sqliteDialect) LikePattern(w Wildcard, ignoreCase bool) string {
+	if ignoreCase {
+		return w.likePattern(d.EscapeLike)
+	}
+
+	return w.globPattern()
+}
+
+func (sqliteDialect) Like(expr string, pattern string, ignoreCase bool) string {
+	// LIKE ignores the case of ASCII characters only, GLOB is case
+	// sensitive.
+	if ignoreCase {
+		return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s) ESCAPE '%s'", expr, pattern, likeEscape)
+	}
+
+	return fmt.Sprintf("%s GLOB %s", expr, pattern)
+}
+
+func (sqliteDialect) Not(expr string) string {
+	return fmt.Sprintf("(%s) IS NOT TRUE", expr)
+}
+
+// SQLite list columns are JSON arrays.
+func (sqliteDialect) Overlaps(expr string, values []string) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(%s) WHERE value IN (%s))", expr, strings.Join(values, ", "))
+}
+
+func (d sqliteDialect) ContainsAll(expr string, values []string) string {
+	return containsEach(d, expr, values)
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Name() string { return "sqlserver" }
+
+func (sqlServerDialect) QuoteIdent(name string) string {
+	return quoteIdentParts(name, "[", "]")
+}
+
+func (sqlServerDialect) QuoteLiteral(value string) string {
+	return "N" + quoteANSILiteral(value)
+}
+
+func (d sqlServerDialect) Value(kind string, value string, bind func(any) string) string {
+	switch kind {
+	case KindDate:
+		return castValue(d, "DATE", value, bind)
+	case KindDateTime:
+		return castValue(d, "DATETIMEOFFSET", value, bind)
+	case KindDuration:
+		return durationSeconds(d, value, bind)
+	}
+
+	return plainValue(d, kind, value, bind)
+}
+
+func (d sqlServerDialect) Now(offset time.Duration, kind string, bind func(any) string) string {
+	now := "SYSDATETIMEOFFSET()"
+	if offset != 0 {
+		seconds := strconv.FormatInt(int64(offset.Seconds()), 10)
+		now = "DATEADD(second, " + plainValue(d, KindNumber, seconds, bind) + ", SYSDATETIMEOFFSET())"
+	}
+	if kind == KindDate {
+		return "CAST(SWITCHOFFSET(" + now + ", '+00:00') AS DATE)"
+	}
+
+	return now
+}
+
+func (sqlServerDialect) EscapeLike(value string) string {
+	// brackets open a character class in SQL Server patterns.
+	return strings.ReplaceAll(likeReplacer.Replace(value), "[", likeEscape+"[")
+}
+
+func (sqlServerDialect) Bool(value bool) string {
+	// SQL Server has no boolean expressions, only predicates.
+	if value {
+		return "1 = 1"
+	}
+
+	return "1 = 0"
+}
+
+func (sqlServerDialect) Placeholder(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+func (sqlServerDialect) Collate(expr string, _ bool) string {
+	return expr + " COLLATE Latin1_General_BIN2"
+}
+
+func (sqlServerDialect) Position(needle string, haystack string) string {
+	// CHARINDEX doesn't find an empty needle, = and LEN ignore trailing
+	// spaces.
+	return fmt.Sprintf("(CHARINDEX(%s, %s) > 0 OR DATALENGTH(%s) = 0)", needle, haystack, needle)
+}
+
+func (d sqlServerDialect) LikePattern(w Wildcard, _ bool) string {
+	return w.likePattern(d.EscapeLike)
+}
+
+func (sqlServerDialect) Like(expr string, pattern string, ignoreCase bool) string {
+	if ignoreCase {
+		return fmt.Sprintf("%s COLLATE Latin1_General_CI_AS LIKE %s ESCAPE '%s'", expr, pattern, likeEscape)
+	}
+
+	return fmt.Sprintf("%s COLLATE Latin1_General_CS_AS LIKE %s ESCAPE '%s'", expr, pattern, likeEscape)
+}
+
+func (sqlServerDialect) Not(expr string) string {
+	// IS NOT TRUE is not supported, predicates can only be used in CASE.
+	return fmt.Sprintf("CASE WHEN %s THEN 0 ELSE 1 END = 1", expr)
+}
+
+// SQL Server list columns are JSON arrays.
+func (sqlServerDialect) Overlaps(expr string, values []string) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM OPENJSON(%s) WHERE value IN (%s))", expr, strings.Join(values, ", "))
+}
+
+func (d sqlServerDialect) ContainsAll(expr string, values []string) string {
+	return containsEach(d, expr, values)
+}
@@ -0,0 +1,357 @@
+package rulejson
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ElasticBuilder compiles evaluated rules into Elasticsearch and
+// OpenSearch bool queries. Data attributes compile to fields, strings are
+// matched exactly with term level queries and numbers, dates and
+// durations as numeric and date values. Missing fields follow the NULL
+// convention of SQLBuilder.
+type ElasticBuilder struct {
+	// KeywordSuffix is appended to the fields of string attributes, like
+	// .keyword for text fields with a keyword sub-field.
+	KeywordSuffix string
+	// Clock, if set, fixes the time relative time operators compare
+	// against. Otherwise they use date math relative to now.
+	Clock func() time.Time
+}
+
+// elasticRanges maps the SQL comparison operators to range parameters.
+var elasticRanges = map[string]string{
+	"<":  "lt",
+	"<=": "lte",
+	">":  "gt",
+	">=": "gte",
+}
+
+// Compile renders rule, usually the result of Evaluate, as a query. Rules
+// decided during evaluation match all or no documents.
+func (b *ElasticBuilder) Compile(rule *Rule) (map[string]any, error) {
+	if rule.Decision.Decided() {
+		return elasticBool(rule.Decision == True), nil
+	}
+
+	switch rule.Type {
+	case "bool":
+		switch rule.Operator {
+		case "true":
+			return elasticBool(true), nil
+		case "false":
+			return elasticBool(false), nil
+		}
+
+		return nil, fmt.Errorf("rule `%s` has invalid bool value `%s`", rule.Name, rule.Operator)
+	case "attribute":
+		return b.compileTarget(rule)
+	case "comparison":
+		return b.compileComparison(rule)
+	case "group":
+		if rule.Operator == "NOT" {
+			if len(rule.Items) != 1 {
+				return nil, fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+			}
+			item, err := b.Compile(&rule.Items[0])
+			if err != nil {
+				return nil, err
+			}
+
+			// must_not matches documents the item doesn't, like IS NOT TRUE.
+			return elasticQuery("bool", map[string]any{"must_not": []any{item}}), nil
+		}
+		items := make([]any, len(rule.Items))
+		for i := range rule.Items {
+			item, err := b.Compile(&rule.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+
+		switch rule.Operator {
+		case "AND":
+			return elasticQuery("bool", map[string]any{"must": items}), nil
+		case "OR":
+			return elasticShould(items), nil
+		}
+
+		return nil, fmt.Errorf("rule `%s` has unknown group operator `%s`", rule.Name, rule.Operator)
+	}
+
+	return nil, fmt.Errorf("allowed rule types are `attribute`, `bool`, `comparison` or `group` got: `%s`", rule.Type)
+}
+
+func elasticQuery(name string, body any) map[string]any {
+	return map[string]any{name: body}
+}
+
+func elasticBool(value bool) map[string]any {
+	if value {
+		return elasticQuery("match_all", map[string]any{})
+	}
+
+	return elasticQuery("match_none", map[string]any{})
+}
+
+func elasticShould(queries []any) map[string]any {
+	return elasticQuery("bool", map[string]any{"should": queries, "minimum_should_match": 1})
+}
+
+// elasticExcept matches documents that have field but don't match query,
+// must_not alone would match documents without the field.
+func elasticExcept(field string, query any) map[string]any {
+	return elasticQuery("bool", map[string]any{
+		"filter":   []any{elasticQuery("exists", map[string]any{"field": field})},
+		"must_not": []any{query},
+	})
+}
+
+// field returns the field of a data attribute, with the keyword suffix
+// for strings.
+func (b *ElasticBuilder) field(rule *Rule, attr RuleAttribute) (string, error) {
+	field, err := dataColumn(attr.Name)
+	if err != nil {
+		return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+	if attr.Kind == "" || attr.Kind == KindString {
+		field += b.KeywordSuffix
+	}
+
+	return field, nil
+}
+
+// elasticValue converts value to the JSON type values of kind are
+// indexed as, numbers as numbers, dates in their ISO-8601 form and
+// durations as seconds.
+func elasticValue(value string, kind string) any {
+	parsed, err := parseValue(kind, value)
+	if err != nil {
+		return value
+	}
+	switch v := parsed.(type) {
+	case float64:
+		return v
+	case time.Time:
+		return formatTime(v, kind)
+	case time.Duration:
+		return v.Seconds()
+	}
+
+	return value
+}
+
+func elasticValues(values []string, kind string) []any {
+	converted := make([]any, len(values))
+	for i := range values {
+		converted[i] = elasticValue(values[i], kind)
+	}
+
+	return converted
+}
+
+var elasticWildcardReplacer = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+
+func elasticWildcard(field string, w Wildcard, ignoreCase bool) map[string]any {
+	query := map[string]any{"value": w.translate(elasticWildcardReplacer.Replace, "*", "?")}
+	if ignoreCase {
+		query["case_insensitive"] = true
+	}
+
+	return elasticQuery("wildcard", map[string]any{field: query})
+}
+
+// threshold returns the time a relative time operator compares against,
+// date math rounded to the day for dates if there is no clock.
+func (b *ElasticBuilder) threshold(operator string, target *TargetRelative, kind string) string {
+	if b.Clock != nil {
+		return formatTime(target.threshold(operator, kind, b.Clock()), kind)
+	}
+
+	math := "now"
+	if offset := target.offset(operator); offset != 0 {
+		math += fmt.Sprintf("%+ds", int64(offset.Seconds()))
+	}
+	if kind == KindDate {
+		math += "/d"
+	}
+
+	return math
+}
+
+func (b *ElasticBuilder) compileTarget(rule *Rule) (map[string]any, error) {
+	if rule.ParsedTarget == nil {
+		return nil, fmt.Errorf("rule `%s` has no parsed target, was it validated?", rule.Name)
+	}
+	kind := rule.Attribute.Kind
+	field, err := b.field(rule, rule.Attribute)
+	if err != nil {
+		return nil, err
+	}
+
+	switch target := rule.ParsedTarget.(type) {
+	case *TargetValue:
+		value := elasticValue(target.Value, kind)
+		switch rule.Operator {
+		case "equal":
+			return elasticQuery("term", map[string]any{field: value}), nil
+		case "notEqual":
+			return elasticExcept(field, elasticQuery("term", map[string]any{field: value})), nil
+		case "greaterThan":
+			return elasticQuery("range", map[string]any{field: map[string]any{"gt": value}}), nil
+		case "lessThan":
+			return elasticQuery("range", map[string]any{field: map[string]any{"lt": value}}), nil
+		case "isSubstringOf":
+			// the field is a substring of the value, there is no query
+			// for it.
+			return elasticQuery("script", map[string]any{"script": map[string]any{
+				"source": "doc[params.field].size() > 0 && params.value.contains(doc[params.field].value)",
+				"params": map[string]any{"field": field, "value": target.Value},
+			}}), nil
+		case "startsWith":
+			return elasticQuery("prefix", map[string]any{field: target.Value}), nil
+		case "endsWith":
+			return elasticWildcard(field, Wildcard{{kind: wildcardAny}, {literal: target.Value}}, false), nil
+		}
+	case *TargetValues:
+		values := elasticValues(target.Values, kind)
+		switch rule.Operator {
+		case "in", "anyOf", "intersects":
+			// terms matches a single value field as well as an array with
+			// one of the values.
+			return elasticQuery("terms", map[string]any{field: values}), nil
+		case "notIn":
+			return elasticExcept(field, elasticQuery("terms", map[string]any{field: values})), nil
+		case "allOf":
+			terms := make([]any, len(values))
+			for i := range values {
+				terms[i] = elasticQuery("term", map[string]any{field: values[i]})
+			}
+
+			return elasticQuery("bool", map[string]any{"must": terms}), nil
+		}
+	case *TargetRange:
+		return elasticQuery("range", map[string]any{field: map[string]any{
+			"gte": elasticValue(target.From, kind),
+			"lte": elasticValue(target.To, kind),
+		}}), nil
+	case *TargetWildcard:
+		w, err := target.Wildcard()
+		if err != nil {
+			return nil, fmt.Errorf("rule `%s`: invalid wildcard pattern: %w", rule.Name, err)
+		}
+
+		return elasticWildcard(field, w, target.IgnoreCase), nil
+	case *TargetNull:
+		return elasticQuery("bool", map[string]any{
+			"must_not": []any{elasticQuery("exists", map[string]any{"field": field})},
+		}), nil
+	case *TargetRelative:
+		threshold := b.threshold(rule.Operator, target, kind)
+
+		return elasticQuery("range", map[string]any{field: map[string]any{
+			elasticRanges[relativeOperators[rule.Operator]]: threshold,
+		}}), nil
+	}
+
+	return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+}
+
+func (b *ElasticBuilder) compileComparison(rule *Rule) (map[string]any, error) {
+	if len(rule.Attributes) != 2 {
+		return nil, fmt.Errorf("rule `%s` with type `comparison` must have exactly two attributes", rule.Name)
+	}
+
+	left, right := rule.Attributes[0], rule.Attributes[1]
+	operator := rule.Operator
+	if operator == "contains" {
+		left, right, operator = right, left, "in"
+	}
+
+	leftValues, leftResolved := rule.Resolved[left.Name]
+	rightValues, rightResolved := rule.Resolved[right.Name]
+	switch {
+	case leftResolved && rightResolved:
+		return elasticBool(evaluateComparison(operator, leftValues, rightValues, kindOf(left))), nil
+	case operator == "in" && leftResolved:
+		// the field, or one of its values, is one of the values.
+		return b.compareList(rule, right, "=", leftValues, left.Kind)
+	case operator == "in" && rightResolved:
+		return b.compareList(rule, left, "=", rightValues, right.Kind)
+	case operator == "in" && right.Multi:
+		return b.compareFields(rule, left, right, "doc[params.right].contains(doc[params.left].value)")
+	case operator == "in":
+		operator = "equal"
+	}
+
+	op, ok := comparisonOperators[operator]
+	if !ok {
+		return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+	}
+	switch {
+	case leftResolved:
+		return b.compareList(rule, right, flipOperator(op), leftValues, left.Kind)
+	case rightResolved:
+		return b.compareList(rule, left, op, rightValues, right.Kind)
+	}
+
+	if op == "<>" {
+		op = "!="
+	}
+	if op == "=" {
+		op = "=="
+	}
+
+	return b.compareFields(rule, left, right, "doc[params.left].value.compareTo(doc[params.right].value) "+op+" 0")
+}
+
+// compareList compares the field of attr against resolved values, it holds
+// if it holds for one of them. Like in SQL, <> must hold for all.
+func (b *ElasticBuilder) compareList(rule *Rule, attr RuleAttribute, op string, values []string, kind string) (map[string]any, error) {
+	if len(values) == 0 {
+		return elasticBool(op == "<>"), nil
+	}
+	field, err := b.field(rule, attr)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := elasticValues(values, kind)
+	switch op {
+	case "=":
+		return elasticQuery("terms", map[string]any{field: converted}), nil
+	case "<>":
+		return elasticExcept(field, elasticQuery("terms", map[string]any{field: converted})), nil
+	}
+
+	ranges := make([]any, len(converted))
+	for i := range converted {
+		ranges[i] = elasticQuery("range", map[string]any{field: map[string]any{elasticRanges[op]: converted[i]}})
+	}
+	if len(ranges) == 1 {
+		//nolint:forcetypeassert
+		return ranges[0].(map[string]any), nil
+	}
+
+	return elasticShould(ranges), nil
+}
+
+// compareFields compares two fields of a document with a script, documents
+// without either field don't match.
+func (b *ElasticBuilder) compareFields(rule *Rule, left RuleAttribute, right RuleAttribute, condition string) (map[string]any, error) {
+	leftField, err := b.field(rule, left)
+	if err != nil {
+		return nil, err
+	}
+	rightField, err := b.field(rule, right)
+	if err != nil {
+		return nil, err
+	}
+
+	return elasticQuery("script", map[string]any{"script": map[string]any{
+		"source": "doc[params.left].size() > 0 && doc[params.right].size() > 0 && " + condition,
+		"params": map[string]any{"left": leftField, "right": rightField},
+	}}), nil
+}
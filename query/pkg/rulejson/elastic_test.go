@@ -0,0 +1,163 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestElasticBuilder(t *testing.T) {
+	attribute := func(name string, kind string, operator string, assert string) *Rule {
+		rule := &Rule{
+			Name:      "rule",
+			Type:      "attribute",
+			Operator:  operator,
+			Attribute: RuleAttribute{Name: name, Kind: kind},
+		}
+		if assert != "" {
+			rule.Assert = json.RawMessage(assert)
+		}
+
+		return rule
+	}
+
+	tests := []struct {
+		name  string
+		rule  *Rule
+		input Input
+		clock func() time.Time
+		want  string
+	}{
+		{
+			name: "keyword",
+			rule: attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+			want: `{"term":{"city.keyword":"Hamburg"}}`,
+		},
+		{
+			name: "number",
+			rule: attribute("data.age", KindNumber, "greaterThan", `{"value": "18"}`),
+			want: `{"range":{"age":{"gt":18}}}`,
+		},
+		{
+			name: "notEqual requires the field",
+			rule: attribute("data.age", KindNumber, "notEqual", `{"value": "18"}`),
+			want: `{"bool":{"filter":[{"exists":{"field":"age"}}],"must_not":[{"term":{"age":18}}]}}`,
+		},
+		{
+			name: "terms",
+			rule: attribute("data.city", "", "in", `{"values": ["Hamburg", "Berlin"]}`),
+			want: `{"terms":{"city.keyword":["Hamburg","Berlin"]}}`,
+		},
+		{
+			name: "allOf",
+			rule: attribute("data.tags", "", "allOf", `{"values": ["red", "blue"]}`),
+			want: `{"bool":{"must":[{"term":{"tags.keyword":"red"}},{"term":{"tags.keyword":"blue"}}]}}`,
+		},
+		{
+			name: "range of dates",
+			rule: attribute("data.created", KindDate, "range", `{"from": "2024-01-01", "to": "2024-12-31"}`),
+			want: `{"range":{"created":{"gte":"2024-01-01","lte":"2024-12-31"}}}`,
+		},
+		{
+			name: "wildcard",
+			rule: attribute("data.name", "", "matchesWildcard", `{"value": "J?hn*\\*", "ignoreCase": true}`),
+			want: `{"wildcard":{"name.keyword":{"case_insensitive":true,"value":"j?hn*\\*"}}}`,
+		},
+		{
+			name: "startsWith",
+			rule: attribute("data.path", "", "startsWith", `{"value": "/home"}`),
+			want: `{"prefix":{"path.keyword":"/home"}}`,
+		},
+		{
+			name: "isNull",
+			rule: attribute("data.city", "", "isNull", ""),
+			want: `{"bool":{"must_not":[{"exists":{"field":"city.keyword"}}]}}`,
+		},
+		{
+			name: "withinLast date math",
+			rule: attribute("data.created", KindDate, "withinLast", `{"duration": "P7D"}`),
+			want: `{"range":{"created":{"gte":"now-604800s/d"}}}`,
+		},
+		{
+			name:  "withinLast clock",
+			rule:  attribute("data.created", KindDateTime, "withinLast", `{"duration": "PT1H"}`),
+			clock: func() time.Time { return time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC) },
+			want:  `{"range":{"created":{"gte":"2024-06-15T11:00:00Z"}}}`,
+		},
+		{
+			name: "groups",
+			rule: &Rule{Type: "group", Operator: "OR", Items: []Rule{
+				*attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+				{Type: "group", Operator: "NOT", Items: []Rule{*attribute("data.age", KindNumber, "lessThan", `{"value": "18"}`)}},
+			}},
+			want: `{"bool":{"minimum_should_match":1,"should":[{"term":{"city.keyword":"Hamburg"}},{"bool":{"must_not":[{"range":{"age":{"lt":18}}}]}}]}}`,
+		},
+		{
+			name: "decided",
+			rule: &Rule{Type: "group", Operator: "OR", Items: []Rule{
+				*attribute("user.role", "", "equal", `{"value": "admin"}`),
+				*attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+			}},
+			input: Input{"user.role": {"admin"}},
+			want:  `{"match_all":{}}`,
+		},
+		{
+			name: "comparison with user",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "gte",
+				Attributes: []RuleAttribute{{Name: "user.level", Kind: KindNumber}, {Name: "data.level", Kind: KindNumber}},
+			},
+			input: Input{"user.level": {"3", "5"}},
+			want:  `{"bool":{"minimum_should_match":1,"should":[{"range":{"level":{"lte":3}}},{"range":{"level":{"lte":5}}}]}}`,
+		},
+		{
+			name: "comparison of fields",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "lt",
+				Attributes: []RuleAttribute{{Name: "data.start", Kind: KindNumber}, {Name: "data.end", Kind: KindNumber}},
+			},
+			want: `{"script":{"script":{"params":{"left":"start","right":"end"},"source":"doc[params.left].size() \u003e 0 \u0026\u0026 doc[params.right].size() \u003e 0 \u0026\u0026 doc[params.left].value.compareTo(doc[params.right].value) \u003c 0"}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			evaluated, err := tt.rule.EvaluateInput(tt.input)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			b := &ElasticBuilder{KeywordSuffix: ".keyword", Clock: tt.clock}
+			query, err := b.Compile(evaluated)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := json.Marshal(query)
+			if err != nil {
+				t.Fatalf("failed to marshal query: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Compile() got = >%s<, want >%v<", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestElasticBuilderUserAttribute(t *testing.T) {
+	rule := &Rule{
+		Name:      "role",
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "user.role"},
+		Assert:    json.RawMessage(`{"value": "admin"}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	if _, err := (&ElasticBuilder{}).Compile(rule); err == nil {
+		t.Errorf("Compile() error = nil, want error for a user attribute")
+	}
+}
@@ -0,0 +1,130 @@
+package rulejson
+
+// Outcomes of a traced rule.
+const (
+	OutcomeTrue     = "true"
+	OutcomeFalse    = "false"
+	OutcomeResidual = "residual"
+	// OutcomeSkipped marks the items of a group after the one that decided
+	// it, they are not evaluated.
+	OutcomeSkipped = "skipped"
+)
+
+// Trace records how a rule and its items were evaluated.
+type Trace struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Operator string `json:"operator"`
+	// Values holds the input values of the attributes of the rule.
+	Values map[string][]string `json:"values,omitempty"`
+	// Missing lists the user and env attributes of the rule that were not
+	// part of the input.
+	Missing []string `json:"missing,omitempty"`
+	Outcome string   `json:"outcome"`
+	// Residual is the SQL left for the database if Outcome is residual.
+	Residual string `json:"residual,omitempty"`
+	// ShortCircuit is the index of the item that decided a group.
+	ShortCircuit *int `json:"shortCircuit,omitempty"`
+	// Denied is set if a missing attribute decided the rule with
+	// MissingDeny.
+	Denied bool    `json:"denied,omitempty"`
+	Items  []Trace `json:"items,omitempty"`
+}
+
+// Explain evaluates rule like EvaluateInput and returns a trace of every
+// rule in the tree. The outcome of the root is the result of EvaluateInput.
+func (rule *Rule) Explain(input Input, opts ...EvaluateOption) (*Trace, error) {
+	ev := newEvaluation(input, rule.Effect, opts)
+
+	trace, err := explainRule(rule, ev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &trace, nil
+}
+
+func explainRule(rule *Rule, ev *evaluation) (Trace, error) {
+	trace := Trace{
+		Name:     rule.Name,
+		Type:     rule.Type,
+		Operator: rule.Operator,
+	}
+
+	var names []string
+	switch rule.Type {
+	case "attribute":
+		names = []string{rule.Attribute.Name}
+	case "comparison":
+		for _, attr := range rule.Attributes {
+			names = append(names, attr.Name)
+		}
+	case "group":
+		// the item of NOT is explained with the negated missing value.
+		itemEv := ev
+		if rule.Operator == "NOT" {
+			negated := *ev
+			negated.missingValue = !negated.missingValue
+			itemEv = &negated
+		}
+		for i := range rule.Items {
+			item := &rule.Items[i]
+			if trace.ShortCircuit != nil {
+				trace.Items = append(trace.Items, Trace{
+					Name:     item.Name,
+					Type:     item.Type,
+					Operator: item.Operator,
+					Outcome:  OutcomeSkipped,
+				})
+
+				continue
+			}
+			child, err := explainRule(item, itemEv)
+			if err != nil {
+				return Trace{}, err
+			}
+			trace.Items = append(trace.Items, child)
+			if (rule.Operator == "AND" && child.Outcome == OutcomeFalse) || (rule.Operator == "OR" && child.Outcome == OutcomeTrue) {
+				index := i
+				trace.ShortCircuit = &index
+			}
+		}
+	}
+	for _, name := range names {
+		values, ok := ev.input[name]
+		if namespace, _, _ := namespaceOf(name); !ok && namespace != NamespaceData {
+			trace.Missing = append(trace.Missing, name)
+		}
+		if !ok {
+			continue
+		}
+		if trace.Values == nil {
+			trace.Values = map[string][]string{}
+		}
+		trace.Values[name] = values
+	}
+	trace.Denied = len(trace.Missing) != 0 && ev.missing == MissingDeny
+
+	// every rule is evaluated on its own, a missing attribute only decides
+	// the rule it belongs to.
+	cop := &Rule{}
+	cloneRule(rule, cop)
+	sub := *ev
+	if err := evaluateRule(cop, &sub); err != nil {
+		return Trace{}, err
+	}
+	trace.Outcome, trace.Residual = outcomeOf(cop)
+
+	return trace, nil
+}
+
+func outcomeOf(rule *Rule) (outcome string, residual string) {
+	switch rule.Decision {
+	case True:
+		return OutcomeTrue, ""
+	case False:
+		return OutcomeFalse, ""
+	}
+
+	return OutcomeResidual, rule.Stringer()
+}
@@ -0,0 +1,149 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	policy := `{
+		"name": "root",
+		"type": "group",
+		"operator": "OR",
+		"items": [
+			{
+				"name": "admins",
+				"type": "attribute",
+				"attribute": {"name": "user.role"},
+				"operator": "equal",
+				"assert": {"value": "admin"}
+			},
+			{
+				"name": "own department",
+				"type": "group",
+				"operator": "AND",
+				"items": [
+					{
+						"name": "department",
+						"type": "comparison",
+						"operator": "equal",
+						"attributes": [{"name": "data.department"}, {"name": "user.department"}]
+					},
+					{
+						"name": "public",
+						"type": "attribute",
+						"attribute": {"name": "data.public"},
+						"operator": "equal",
+						"assert": {"value": "yes"}
+					}
+				]
+			},
+			{
+				"name": "everyone",
+				"type": "bool",
+				"operator": "true"
+			},
+			{
+				"name": "never reached",
+				"type": "bool",
+				"operator": "false"
+			}
+		]
+	}`
+	rule := &Rule{}
+	if err := json.Unmarshal([]byte(policy), rule); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	got, err := rule.Explain(Input{"user.role": {"staff"}, "user.department": {"sales"}})
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	shortCircuit := 2
+	want := &Trace{
+		Name:         "root",
+		Type:         "group",
+		Operator:     "OR",
+		Outcome:      OutcomeTrue,
+		ShortCircuit: &shortCircuit,
+		Items: []Trace{
+			{
+				Name:     "admins",
+				Type:     "attribute",
+				Operator: "equal",
+				Values:   map[string][]string{"user.role": {"staff"}},
+				Outcome:  OutcomeFalse,
+			},
+			{
+				Name:     "own department",
+				Type:     "group",
+				Operator: "AND",
+				Outcome:  OutcomeResidual,
+				Residual: `( data.department = 'sales' AND data.public = 'yes' )`,
+				Items: []Trace{
+					{
+						Name:     "department",
+						Type:     "comparison",
+						Operator: "equal",
+						Values:   map[string][]string{"user.department": {"sales"}},
+						Outcome:  OutcomeResidual,
+						Residual: `data.department = 'sales'`,
+					},
+					{
+						Name:     "public",
+						Type:     "attribute",
+						Operator: "equal",
+						Outcome:  OutcomeResidual,
+						Residual: `data.public = 'yes'`,
+					},
+				},
+			},
+			{Name: "everyone", Type: "bool", Operator: "true", Outcome: OutcomeTrue},
+			{Name: "never reached", Type: "bool", Operator: "false", Outcome: OutcomeSkipped},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.MarshalIndent(got, "", "  ")
+		wantJSON, _ := json.MarshalIndent(want, "", "  ")
+		t.Errorf("Explain() got = %s, want %s", gotJSON, wantJSON)
+	}
+}
+
+func TestExplainDenied(t *testing.T) {
+	rule := &Rule{
+		Name:     "root",
+		Type:     "group",
+		Operator: "OR",
+		Items: []Rule{
+			{
+				Name:      "city",
+				Type:      "attribute",
+				Operator:  "equal",
+				Attribute: RuleAttribute{Name: "user.city"},
+				Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+			},
+			{Name: "everyone", Type: "bool", Operator: "true"},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	got, err := rule.Explain(Input{})
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	if got.Outcome != OutcomeTrue || got.Denied {
+		t.Errorf("Explain() got outcome = %v, denied = %v, want true, false", got.Outcome, got.Denied)
+	}
+	if got.Items[0].Outcome != OutcomeFalse || !got.Items[0].Denied {
+		t.Errorf("Explain() got item outcome = %v, denied = %v, want false, true", got.Items[0].Outcome, got.Items[0].Denied)
+	}
+	if !reflect.DeepEqual(got.Items[0].Missing, []string{"user.city"}) {
+		t.Errorf("Explain() got missing = %v, want [user.city]", got.Items[0].Missing)
+	}
+}
@@ -0,0 +1,59 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestExportGolden exports the policies in testdata, the ones of
+// rulejson_test.go and draft.txt, and compares them with the golden files
+// next to them. Run with -update after changing an exporter.
+func TestExportGolden(t *testing.T) {
+	exporters := map[string]interface {
+		Export(rule *Rule) (string, error)
+	}{
+		".rego":  &RegoExporter{},
+		".cedar": &CedarExporter{},
+	}
+
+	for _, name := range []string{"level", "complex", "draft"} {
+		for ext, exporter := range exporters {
+			t.Run(name+ext, func(t *testing.T) {
+				data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+				if err != nil {
+					t.Fatalf("failed to read policy: %v", err)
+				}
+				rule := &Rule{}
+				if err := json.Unmarshal(data, rule); err != nil {
+					t.Fatalf("failed to unmarshal json: %v", err)
+				}
+				if rErr := Validate(rule); len(rErr) != 0 {
+					t.Fatalf("failed to validate rule: %v", rErr)
+				}
+
+				got, err := exporter.Export(rule)
+				if err != nil {
+					t.Fatalf("Export() error = %v", err)
+				}
+				golden := filepath.Join("testdata", name+ext)
+				if *update {
+					if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+						t.Fatalf("failed to update golden file: %v", err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("failed to read golden file: %v", err)
+				}
+				if got != string(want) {
+					t.Errorf("Export() got = >%v<, want >%v<", got, string(want))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,764 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Policy expressions are a textual syntax for rules, like
+//
+//	user.city in ("Hamburg", "Berlin") and data.work_order = "hello"
+//
+// Groups are written with `and`, `or` and `not`, binding in the order
+// not, and, or, and parentheses. A parenthesized group is its own rule:
+// `a and (b and c)` has two items, `a and b and c` three. Groups with a
+// single item are written like calls, `and(a)`, and `true` and `false`
+// are bool rules. `@name` or `@"any name"` before an item sets its name.
+// A leading `permit` or `deny` sets the effect of the policy.
+//
+// Attributes are dotted names, segments that aren't identifiers are quoted
+// in backticks like data.`zip code`, with backticks in them doubled. A kind
+// and the multi-valued flag follow the name: data.age:number, data.tags[]
+// or data.tags:number[].
+// Values are strings in double quotes with Go escapes, or numbers.
+//
+// An attribute is compared with a value or another attribute:
+//
+//	data.age > 18                          greaterThan, lessThan with <
+//	data.city = "Hamburg"                  equal, notEqual with !=
+//	data.city in ("Hamburg", "Berlin")     in, notIn with `not in`
+//	data.age between 18 and 65             range
+//	data.city is null                      isNull
+//	data.tags[] anyOf ("red", "blue")      anyOf, allOf, intersects
+//	data.name matchesWildcard "J*" ignoreCase
+//	data.created:date withinLast "P7D"     also olderThan, beforeNow and
+//	                                       afterNow, whose duration is optional
+//	data.level:number <= user.level:number comparison, with =, !=, <, <=,
+//	                                       >, >=, in and contains
+//
+// isSubstringOf, startsWith and endsWith are written like matchesWildcard.
+// intersects is another name of anyOf for lists read as sets.
+// Evaluation results, the decision and resolved values of a rule, are not
+// part of the syntax.
+
+// SyntaxError is returned by ParseExpression for malformed expressions,
+// Line and Column are 1-based and count characters.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+type exprTokenKind int
+
+const (
+	tokenEOF exprTokenKind = iota
+	tokenIdent
+	// a name segment in backticks.
+	tokenQuoted
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	// the identifier or punctuation, the unquoted string or the number as
+	// written.
+	text   string
+	line   int
+	column int
+}
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	}
+
+	return quoteName(t.text)
+}
+
+// quoteName quotes a name segment in backticks, doubling the backticks in
+// it.
+func quoteName(segment string) string {
+	return "`" + strings.ReplaceAll(segment, "`", "``") + "`"
+}
+
+// exprPunct lists the punctuation, longer ones first.
+var exprPunct = []string{"<=", ">=", "!=", "(", ")", ",", ".", ":", "[", "]", "@", "=", "<", ">"}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lexExpression(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	line, lineStart := 1, 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		column := utf8.RuneCountInString(src[lineStart:i]) + 1
+		fail := func(msg string) ([]exprToken, error) {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: msg}
+		}
+
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+			continue
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i]) || src[i] == '-') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: src[start:i], line: line, column: column})
+
+			continue
+		case isDigit(c) || (c == '-' && i+1 < len(src) && isDigit(src[i+1])):
+			m := numberPattern.FindString(src[i:])
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: m, line: line, column: column})
+			i += len(m)
+
+			continue
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' && src[end] != '\n' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) || src[end] != '"' {
+				return fail("unterminated string")
+			}
+			value, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return fail("invalid string " + src[i:end+1])
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: value, line: line, column: column})
+			i = end + 1
+
+			continue
+		case c == '`':
+			// a doubled backtick is a backtick of the name.
+			var name strings.Builder
+			end := i + 1
+			for {
+				next := strings.IndexAny(src[end:], "`\n")
+				if next < 0 || src[end+next] != '`' {
+					return fail("unterminated quoted name")
+				}
+				name.WriteString(src[end : end+next])
+				end += next + 1
+				if end == len(src) || src[end] != '`' {
+					break
+				}
+				name.WriteByte('`')
+				end++
+			}
+			tokens = append(tokens, exprToken{kind: tokenQuoted, text: name.String(), line: line, column: column})
+			i = end
+
+			continue
+		}
+
+		punct := ""
+		for _, p := range exprPunct {
+			if strings.HasPrefix(src[i:], p) {
+				punct = p
+
+				break
+			}
+		}
+		if punct == "" {
+			r, _ := utf8.DecodeRuneInString(src[i:])
+			return fail(fmt.Sprintf("unexpected character %q", r))
+		}
+		tokens = append(tokens, exprToken{kind: tokenPunct, text: punct, line: line, column: column})
+		i += len(punct)
+	}
+
+	column := utf8.RuneCountInString(src[lineStart:]) + 1
+
+	return append(tokens, exprToken{kind: tokenEOF, line: line, column: column}), nil
+}
+
+var numberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?`)
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// ParseExpression parses a policy expression into a rule, which still has
+// to be validated.
+func ParseExpression(src string) (*Rule, error) {
+	tokens, err := lexExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	effect := ""
+	if p.is(EffectPermit) || p.is(EffectDeny) {
+		effect = p.next().text
+	}
+	rule, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	rule.Effect = effect
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+
+	return rule, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// is reports whether the next token is the identifier or punctuation
+// text.
+func (p *exprParser) is(text string) bool {
+	t := p.peek()
+
+	return (t.kind == tokenIdent || t.kind == tokenPunct) && t.text == text
+}
+
+// accept consumes the next token if it is text.
+func (p *exprParser) accept(text string) bool {
+	if p.is(text) {
+		p.pos++
+
+		return true
+	}
+
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		return p.errorf(t, "expected `%s`, got %s", text, t)
+	}
+
+	return nil
+}
+
+func (p *exprParser) errorf(t exprToken, format string, args ...any) error {
+	return &SyntaxError{Line: t.line, Column: t.column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) or() (*Rule, error) {
+	return p.group("OR", "or", p.and)
+}
+
+func (p *exprParser) and() (*Rule, error) {
+	return p.group("AND", "and", p.unary)
+}
+
+// group parses items separated by keyword, a single item is returned as
+// is.
+func (p *exprParser) group(operator string, keyword string, item func() (*Rule, error)) (*Rule, error) {
+	first, err := item()
+	if err != nil {
+		return nil, err
+	}
+	items := []Rule{*first}
+	for p.accept(keyword) {
+		next, err := item()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *next)
+	}
+	if len(items) == 1 {
+		return first, nil
+	}
+
+	return &Rule{Type: "group", Operator: operator, Items: items}, nil
+}
+
+func (p *exprParser) unary() (*Rule, error) {
+	if p.accept("@") {
+		t := p.next()
+		if t.kind != tokenIdent && t.kind != tokenString {
+			return nil, p.errorf(t, "expected a name after `@`, got %s", t)
+		}
+		rule, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if rule.Name != "" {
+			return nil, p.errorf(t, "item has two names, `%s` and `%s`", t.text, rule.Name)
+		}
+		rule.Name = t.text
+
+		return rule, nil
+	}
+	if p.accept("not") {
+		item, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Rule{Type: "group", Operator: "NOT", Items: []Rule{*item}}, nil
+	}
+
+	return p.primary()
+}
+
+func (p *exprParser) primary() (*Rule, error) {
+	t := p.peek()
+	if p.accept("(") {
+		rule, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		return rule, p.expect(")")
+	}
+	if t.kind == tokenIdent {
+		switch t.text {
+		case "true", "false":
+			p.next()
+
+			return &Rule{Type: "bool", Operator: t.text}, nil
+		case "and", "or":
+			p.next()
+
+			return p.call(strings.ToUpper(t.text))
+		}
+	}
+
+	return p.condition()
+}
+
+// call parses the items of a group written like a call, and(a).
+func (p *exprParser) call(operator string) (*Rule, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	rule := &Rule{Type: "group", Operator: operator, Items: []Rule{}}
+	if p.accept(")") {
+		return rule, nil
+	}
+	for {
+		item, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		rule.Items = append(rule.Items, *item)
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	return rule, p.expect(")")
+}
+
+func (p *exprParser) attribute() (RuleAttribute, error) {
+	var (
+		attr     RuleAttribute
+		segments []string
+	)
+	for {
+		t := p.next()
+		if t.kind != tokenIdent && t.kind != tokenQuoted {
+			return attr, p.errorf(t, "expected an attribute, got %s", t)
+		}
+		segments = append(segments, t.text)
+		if !p.accept(".") {
+			break
+		}
+	}
+	attr.Name = strings.Join(segments, ".")
+
+	if p.accept(":") {
+		t := p.next()
+		if t.kind != tokenIdent {
+			return attr, p.errorf(t, "expected a kind, got %s", t)
+		}
+		attr.Kind = t.text
+	}
+	if p.accept("[") {
+		if err := p.expect("]"); err != nil {
+			return attr, err
+		}
+		attr.Multi = true
+	}
+
+	return attr, nil
+}
+
+func (p *exprParser) value() (string, error) {
+	t := p.next()
+	if t.kind != tokenString && t.kind != tokenNumber {
+		return "", p.errorf(t, "expected a value, got %s", t)
+	}
+
+	return t.text, nil
+}
+
+func (p *exprParser) values() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var values []string
+	for {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !p.accept(",") {
+			break
+		}
+	}
+
+	return values, p.expect(")")
+}
+
+// isValue reports whether the next token starts a value rather than an
+// attribute.
+func (p *exprParser) isValue() bool {
+	kind := p.peek().kind
+
+	return kind == tokenString || kind == tokenNumber
+}
+
+// exprSymbols maps the comparison symbols to the operators of attribute
+// rules, comparing with a value, and of comparison rules.
+var exprSymbols = map[string][2]string{
+	"=":  {"equal", "equal"},
+	"!=": {"notEqual", "notEqual"},
+	"<":  {"lessThan", "lt"},
+	">":  {"greaterThan", "gt"},
+	"<=": {"", "lte"},
+	">=": {"", "gte"},
+}
+
+func (p *exprParser) condition() (*Rule, error) {
+	attr, err := p.attribute()
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{Type: "attribute", Attribute: attr}
+	comparison := func(operator string) (*Rule, error) {
+		other, err := p.attribute()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Rule{Type: "comparison", Operator: operator, Attributes: []RuleAttribute{attr, other}}, nil
+	}
+
+	t := p.next()
+	var target any
+	switch {
+	case t.kind == tokenPunct && exprSymbols[t.text] != [2]string{}:
+		operators := exprSymbols[t.text]
+		if !p.isValue() {
+			return comparison(operators[1])
+		}
+		if operators[0] == "" {
+			return nil, p.errorf(t, "`%s` compares two attributes", t.text)
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		rule.Operator, target = operators[0], &TargetValue{Value: value}
+	case t.kind != tokenIdent:
+		return nil, p.errorf(t, "expected an operator, got %s", t)
+	case t.text == "in":
+		if !p.is("(") {
+			return comparison("in")
+		}
+		values, err := p.values()
+		if err != nil {
+			return nil, err
+		}
+		rule.Operator, target = "in", &TargetValues{Values: values}
+	case t.text == "contains":
+		return comparison("contains")
+	case t.text == "not":
+		if err := p.expect("in"); err != nil {
+			return nil, err
+		}
+		values, err := p.values()
+		if err != nil {
+			return nil, err
+		}
+		rule.Operator, target = "notIn", &TargetValues{Values: values}
+	case t.text == "between":
+		from, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("and"); err != nil {
+			return nil, err
+		}
+		to, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		rule.Operator, target = "range", &TargetRange{From: from, To: to}
+	case t.text == "is":
+		if err := p.expect("null"); err != nil {
+			return nil, err
+		}
+		rule.Operator = "isNull"
+
+		return rule, nil
+	default:
+		rule.Operator = t.text
+		switch empty, _ := decodeTarget(t.text, nil); empty.(type) {
+		case *TargetValues:
+			values, err := p.values()
+			if err != nil {
+				return nil, err
+			}
+			target = &TargetValues{Values: values}
+		case *TargetValue:
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			target = &TargetValue{Value: value}
+		case *TargetWildcard:
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			target = &TargetWildcard{Value: value, IgnoreCase: p.accept("ignoreCase")}
+		case *TargetRelative:
+			// the duration of beforeNow and afterNow is optional.
+			if !p.isValue() && (t.text == "beforeNow" || t.text == "afterNow") {
+				return rule, nil
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			target = &TargetRelative{Duration: value}
+		default:
+			return nil, p.errorf(t, "unknown operator `%s`", t.text)
+		}
+	}
+
+	assert, err := json.Marshal(target)
+	if err != nil {
+		return nil, p.errorf(t, "invalid assert: %v", err)
+	}
+	rule.Assert = assert
+
+	return rule, nil
+}
+
+// exprKeywords are the identifiers names must be quoted to be used as.
+var exprKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "true": true, "false": true,
+	EffectPermit: true, EffectDeny: true,
+}
+
+// exprIdentifier matches the names that need no quotes, like the lexer.
+var exprIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Expression renders rule as a policy expression, ParseExpression returns
+// an equal rule for it. Rules that can't be written, like groups with an
+// unknown operator, are reported as errors.
+func (rule *Rule) Expression() (string, error) {
+	expr, err := printExpression(rule, false)
+	if err != nil || rule.Effect == "" {
+		return expr, err
+	}
+
+	return rule.Effect + " " + expr, nil
+}
+
+// printExpression renders rule, nested groups of several items are
+// parenthesized so they parse as items of their own.
+func printExpression(rule *Rule, nested bool) (string, error) {
+	label := ""
+	if rule.Name != "" {
+		label = "@" + rule.Name + " "
+		if !exprIdentifier.MatchString(rule.Name) || exprKeywords[rule.Name] {
+			label = "@" + strconv.Quote(rule.Name) + " "
+		}
+	}
+
+	switch rule.Type {
+	case "bool":
+		if rule.Operator != "true" && rule.Operator != "false" {
+			return "", fmt.Errorf("rule `%s` has invalid bool value `%s`", rule.Name, rule.Operator)
+		}
+
+		return label + rule.Operator, nil
+	case "attribute":
+		condition, err := printCondition(rule)
+		if err != nil {
+			return "", err
+		}
+
+		return label + condition, nil
+	case "comparison":
+		if len(rule.Attributes) != 2 {
+			return "", fmt.Errorf("rule `%s` with type `comparison` must have exactly two attributes", rule.Name)
+		}
+		symbol := ""
+		for s, operators := range exprSymbols {
+			if operators[1] == rule.Operator {
+				symbol = s
+			}
+		}
+		if rule.Operator == "in" || rule.Operator == "contains" {
+			symbol = rule.Operator
+		}
+		if symbol == "" {
+			return "", fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+		}
+
+		return label + printAttribute(rule.Attributes[0]) + " " + symbol + " " + printAttribute(rule.Attributes[1]), nil
+	case "group":
+		keyword := strings.ToLower(rule.Operator)
+		items := make([]string, len(rule.Items))
+		for i := range rule.Items {
+			item, err := printExpression(&rule.Items[i], len(rule.Items) > 1 || rule.Operator == "NOT")
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+
+		switch {
+		case rule.Operator == "NOT" && len(items) == 1:
+			return label + "not " + items[0], nil
+		case rule.Operator == "NOT":
+			return "", fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+		case rule.Operator != "AND" && rule.Operator != "OR":
+			return "", fmt.Errorf("rule `%s` has unknown group operator `%s`", rule.Name, rule.Operator)
+		case len(items) < 2:
+			return label + keyword + "(" + strings.Join(items, "") + ")", nil
+		case nested || label != "":
+			return label + "(" + strings.Join(items, " "+keyword+" ") + ")", nil
+		}
+
+		return strings.Join(items, " "+keyword+" "), nil
+	}
+
+	return "", fmt.Errorf("allowed rule types are `attribute`, `bool`, `comparison` or `group` got: `%s`", rule.Type)
+}
+
+func printAttribute(attr RuleAttribute) string {
+	segments := strings.Split(attr.Name, ".")
+	for i, segment := range segments {
+		if !exprIdentifier.MatchString(segment) || exprKeywords[segment] {
+			segments[i] = quoteName(segment)
+		}
+	}
+	name := strings.Join(segments, ".")
+	if attr.Kind != "" {
+		name += ":" + attr.Kind
+	}
+	if attr.Multi {
+		name += "[]"
+	}
+
+	return name
+}
+
+// printValue renders value as a number if attributes of kind are numbers.
+func printValue(value string, kind string) string {
+	if kind == KindNumber && numberPattern.FindString(value) == value {
+		return value
+	}
+
+	return strconv.Quote(value)
+}
+
+func printValues(values []string, kind string) string {
+	printed := make([]string, len(values))
+	for i := range values {
+		printed[i] = printValue(values[i], kind)
+	}
+
+	return "(" + strings.Join(printed, ", ") + ")"
+}
+
+func printCondition(rule *Rule) (string, error) {
+	target, err := decodeTarget(rule.Operator, rule.Assert)
+	if err != nil {
+		return "", fmt.Errorf("rule `%s`: could not decode rule target: %w", rule.Name, err)
+	}
+	attr := printAttribute(rule.Attribute)
+	kind := rule.Attribute.Kind
+
+	switch target := target.(type) {
+	case *TargetValue:
+		value := printValue(target.Value, kind)
+		for symbol, operators := range exprSymbols {
+			if operators[0] == rule.Operator {
+				return attr + " " + symbol + " " + value, nil
+			}
+		}
+
+		return attr + " " + rule.Operator + " " + value, nil
+	case *TargetValues:
+		operator := rule.Operator
+		if operator == "notIn" {
+			operator = "not in"
+		}
+
+		return attr + " " + operator + " " + printValues(target.Values, kind), nil
+	case *TargetRange:
+		return attr + " between " + printValue(target.From, kind) + " and " + printValue(target.To, kind), nil
+	case *TargetNull:
+		return attr + " is null", nil
+	case *TargetWildcard:
+		condition := attr + " matchesWildcard " + strconv.Quote(target.Value)
+		if target.IgnoreCase {
+			condition += " ignoreCase"
+		}
+
+		return condition, nil
+	case *TargetRelative:
+		if target.Duration == "" {
+			return attr + " " + rule.Operator, nil
+		}
+
+		return attr + " " + rule.Operator + " " + strconv.Quote(target.Duration), nil
+	}
+
+	return "", fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+}
@@ -0,0 +1,195 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "request example",
+			src:  `user.city in ("Hamburg","Berlin") and data.work_order = "hello"`,
+			want: `( true AND data.work_order = 'hello' )`,
+		},
+		{
+			name: "precedence",
+			src:  `data.a = "1" or not data.b = "2" and data.c = "3"`,
+			want: `( data.a = '1' OR ( ( NOT data.b = '2' ) AND data.c = '3' ) )`,
+		},
+		{
+			name: "parentheses",
+			src:  `(data.a = "1" or data.b = "2") and true`,
+			want: `( ( data.a = '1' OR data.b = '2' ) AND true )`,
+		},
+		{
+			name: "single item group",
+			src:  `and(data.a = "1")`,
+			want: `( data.a = '1' )`,
+		},
+		{
+			name: "kinds",
+			src:  `data.age:number between 18 and 65.5 and data.created:date withinLast "P7D"`,
+			want: `( data.age BETWEEN 18 AND 65.5 AND data.created >= CAST((now() - INTERVAL 'P7D') AT TIME ZONE 'UTC' AS DATE) )`,
+		},
+		{
+			name: "operators",
+			src:  "data.`zip code` not in (\"1\") or data.name matchesWildcard \"J*\" ignoreCase or data.c is null",
+			want: "( data.zip code NOT IN ('1') OR data.name ILIKE 'j%' ESCAPE '!' OR data.c IS NULL )",
+		},
+		{
+			name: "comparison",
+			src:  `data.level:number <= data.max:number`,
+			want: `data.level <= data.max`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseExpression(tt.src)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			rule, err = rule.EvaluateInput(Input{"user.city": {"Berlin"}})
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			if got := rule.Stringer(); got != tt.want {
+				t.Errorf("ParseExpression() got = >%v<, want >%v<", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		line   int
+		column int
+	}{
+		{name: "missing value", src: `data.a =`, line: 1, column: 9},
+		{name: "unknown operator", src: "data.a = \"1\" and\n  data.b like \"2\"", line: 2, column: 10},
+		{name: "unterminated string", src: `data.a = "1`, line: 1, column: 10},
+		{name: "unclosed group", src: `(data.a = "1"`, line: 1, column: 14},
+		{name: "attributes only", src: `data.a >= 1`, line: 1, column: 8},
+		{name: "two names", src: `@a @b data.a = "1"`, line: 1, column: 2},
+		{name: "trailing", src: `true false`, line: 1, column: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpression(tt.src)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("ParseExpression() error = %v, want a SyntaxError", err)
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column {
+				t.Errorf("ParseExpression() got = >%d:%d<, want >%d:%d< (%v)", syntaxErr.Line, syntaxErr.Column, tt.line, tt.column, err)
+			}
+		})
+	}
+}
+
+func TestExpressionDraft(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "draft.json"))
+	if err != nil {
+		t.Fatalf("failed to read policy: %v", err)
+	}
+	rule := &Rule{}
+	if err := json.Unmarshal(data, rule); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+
+	got, err := rule.Expression()
+	if err != nil {
+		t.Fatalf("Expression() error = %v", err)
+	}
+	want := `true and (and(user.city:string = "Hamburg") or and(user.city:string = "Berlin")) and data.work_order:string = "hello" and (and(data.work_order:string = "world") or and(data.work_order:string = "world3"))`
+	if got != want {
+		t.Errorf("Expression() got = >%v<, want >%v<", got, want)
+	}
+}
+
+// normalizeRule drops the assert of validated rules, their parsed target
+// holds it independent of its JSON formatting.
+func normalizeRule(rule *Rule) {
+	rule.Assert = nil
+	for i := range rule.Items {
+		normalizeRule(&rule.Items[i])
+	}
+}
+
+func TestExpressionRoundTrip(t *testing.T) {
+	policies := []string{
+		`{"name": "x", "type": "group", "operator": "OR", "items": [
+			{"type": "group", "operator": "AND", "items": [
+				{"type": "bool", "operator": "false"},
+				{"type": "group", "operator": "AND", "items": [{"type": "bool", "operator": "true"}, {"type": "bool", "operator": "true"}]}
+			]},
+			{"name": "negated group", "type": "group", "operator": "NOT", "items": [
+				{"type": "group", "operator": "OR", "items": [{"type": "bool", "operator": "true"}, {"type": "bool", "operator": "false"}]}
+			]}
+		]}`,
+		`{"type": "group", "operator": "AND", "items": [
+			{"name": "and", "type": "attribute", "operator": "notIn", "attribute": {"name": "data.not.in"}, "assert": {"values": ["a\"b", "c\\d"]}},
+			{"type": "attribute", "operator": "range", "attribute": {"name": "data.age", "kind": "number"}, "assert": {"from": "-1.5", "to": "007"}},
+			{"type": "attribute", "operator": "range", "attribute": {"name": "data.code"}, "assert": {"from": "1", "to": "2"}},
+			{"type": "attribute", "operator": "beforeNow", "attribute": {"name": "data.due", "kind": "datetime"}},
+			{"type": "attribute", "operator": "afterNow", "attribute": {"name": "data.due", "kind": "datetime"}, "assert": {"duration": "-P1D"}},
+			{"type": "attribute", "operator": "isNull", "attribute": {"name": "data.tags", "multi": true}},
+			{"type": "attribute", "operator": "allOf", "attribute": {"name": "data.tags", "kind": "number", "multi": true}, "assert": {"values": ["1", "2"]}},
+			{"type": "attribute", "operator": "endsWith", "attribute": {"name": "env.host-name"}, "assert": {"value": ".example.com"}},
+			{"type": "attribute", "operator": "matchesWildcard", "attribute": {"name": "data.path"}, "assert": {"value": "a\\*b?"}},
+			{"type": "comparison", "operator": "contains", "attributes": [{"name": "data.groups", "multi": true}, {"name": "user.group"}]},
+			{"type": "comparison", "operator": "notEqual", "attributes": [{"name": "data.owner"}, {"name": "user.id"}]}
+		]}`,
+		// backticks in quoted name segments are doubled.
+		"{\"type\": \"group\", \"operator\": \"OR\", \"items\": [" +
+			"{\"type\": \"attribute\", \"operator\": \"equal\", \"attribute\": {\"name\": \"data.x`y\"}, \"assert\": {\"value\": \"a\"}}," +
+			"{\"type\": \"attribute\", \"operator\": \"equal\", \"attribute\": {\"name\": \"data.``.`z`\"}, \"assert\": {\"value\": \"b\"}}]}",
+		`{"name": "deny", "effect": "deny", "type": "attribute", "operator": "equal", "attribute": {"name": "data.status"}, "assert": {"value": "blocked"}}`,
+	}
+	for _, name := range []string{"level", "complex", "draft"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+		if err != nil {
+			t.Fatalf("failed to read policy: %v", err)
+		}
+		policies = append(policies, string(data))
+	}
+
+	for i, policy := range policies {
+		want := &Rule{}
+		if err := json.Unmarshal([]byte(policy), want); err != nil {
+			t.Fatalf("%d: failed to unmarshal json: %v", i, err)
+		}
+		src, err := want.Expression()
+		if err != nil {
+			t.Fatalf("%d: Expression() error = %v", i, err)
+		}
+		got, err := ParseExpression(src)
+		if err != nil {
+			t.Fatalf("%d: ParseExpression(%s) error = %v", i, src, err)
+		}
+
+		// validation fills in names and parsed targets on both.
+		Validate(want)
+		Validate(got)
+		normalizeRule(want)
+		normalizeRule(got)
+		if !reflect.DeepEqual(got, want) {
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			t.Errorf("%d: round trip of >%s< got = >%s<, want >%s<", i, src, gotJSON, wantJSON)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package rulejson
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attribute kinds. The kind decides how values are parsed, compared and
+// rendered in SQL, an empty kind is treated like `string`.
+const (
+	KindString   = "string"
+	KindNumber   = "number"
+	KindDate     = "date"
+	KindDateTime = "datetime"
+	KindDuration = "duration"
+)
+
+// Dates use the ISO-8601 calendar date format (2024-12-31), datetimes
+// RFC 3339 with a mandatory offset (2024-12-31T23:00:00+01:00) and
+// durations the ISO-8601 duration format (P1DT12H).
+const dateLayout = "2006-01-02"
+
+// parseValue parses value as kind. Values of kind string, or an unknown
+// kind, are returned unchanged.
+func parseValue(kind string, value string) (any, error) {
+	switch kind {
+	case KindNumber:
+		return strconv.ParseFloat(value, 64)
+	case KindDate:
+		return time.Parse(dateLayout, value)
+	case KindDateTime:
+		return time.Parse(time.RFC3339Nano, value)
+	case KindDuration:
+		return ParseDuration(value)
+	}
+
+	return value, nil
+}
+
+// compareValues orders value relative to target according to kind. It
+// returns false if either can't be parsed as kind. Kinds other than
+// string compare their parsed value, so 2024-01-01T01:00:00+01:00 equals
+// 2024-01-01T00:00:00Z.
+func compareValues(value string, target string, kind string) (int, bool) {
+	v, err := parseValue(kind, value)
+	if err != nil {
+		return 0, false
+	}
+	t, err := parseValue(kind, target)
+	if err != nil {
+		return 0, false
+	}
+
+	return compareParsed(v, t)
+}
+
+// compareParsed orders two values parsed as the same kind, it returns
+// false if their types differ.
+func compareParsed(v any, t any) (int, bool) {
+	switch v := v.(type) {
+	case float64:
+		t, ok := t.(float64)
+		return compareOrdered(v, t), ok
+	case time.Time:
+		t, ok := t.(time.Time)
+		return v.Compare(t), ok
+	case time.Duration:
+		t, ok := t.(time.Duration)
+		return compareOrdered(v, t), ok
+	case string:
+		t, ok := t.(string)
+		return strings.Compare(v, t), ok
+	}
+
+	return 0, false
+}
+
+func compareOrdered[T float64 | time.Duration](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+var durationPattern = regexp.MustCompile(`^(-)?P(?:(\d+(?:\.\d+)?)Y)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// durationUnits are the lengths of the designators in durationPattern,
+// years and months use the convention Postgres compares intervals with:
+// 30 days a month and 12 months a year.
+var durationUnits = []time.Duration{
+	12 * 30 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+	24 * time.Hour,
+	time.Hour,
+	time.Minute,
+	time.Second,
+}
+
+// ParseDuration parses an ISO-8601 duration such as P1Y2M10DT2H30M.
+func ParseDuration(value string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(value)
+	if m == nil || strings.HasSuffix(value, "P") || strings.HasSuffix(value, "T") {
+		return 0, errors.New("invalid ISO-8601 duration `" + value + "`")
+	}
+
+	var d time.Duration
+	for i, unit := range durationUnits {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[i+2], 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// formatDuration formats d as an ISO-8601 duration, in days if it is a
+// whole number of days and in seconds otherwise.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	if d%(24*time.Hour) == 0 {
+		return sign + "P" + strconv.FormatInt(int64(d/(24*time.Hour)), 10) + "D"
+	}
+
+	return sign + "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
+
+// formatTime formats t as a value of kind date or datetime.
+func formatTime(t time.Time, kind string) string {
+	if kind == KindDate {
+		return t.UTC().Format(dateLayout)
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,206 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "P90D", want: 90 * 24 * time.Hour},
+		{value: "PT1H30M", want: 90 * time.Minute},
+		{value: "P1DT0.5S", want: 24*time.Hour + 500*time.Millisecond},
+		{value: "P1W", want: 7 * 24 * time.Hour},
+		{value: "P1Y2M", want: 14 * 30 * 24 * time.Hour},
+		{value: "-PT10S", want: -10 * time.Second},
+		{value: "P", wantErr: true},
+		{value: "P1DT", wantErr: true},
+		{value: "1h", wantErr: true},
+		{value: "PT1D", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateTargetKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		target   any
+		input    string
+		kind     string
+		wantBool bool
+	}{
+		{
+			name:     "date in range",
+			operator: "range",
+			target:   &TargetRange{From: "2024-01-01", To: "2024-12-31"},
+			input:    "2024-06-15",
+			kind:     KindDate,
+			wantBool: true,
+		},
+		{
+			name:     "date out of range",
+			operator: "range",
+			target:   &TargetRange{From: "2024-01-01", To: "2024-12-31"},
+			input:    "2025-01-01",
+			kind:     KindDate,
+			wantBool: false,
+		},
+		{
+			name:     "datetime equal across offsets",
+			operator: "equal",
+			target:   &TargetValue{Value: "2024-01-01T00:00:00Z"},
+			input:    "2024-01-01T01:00:00+01:00",
+			kind:     KindDateTime,
+			wantBool: true,
+		},
+		{
+			name:     "datetime without offset",
+			operator: "equal",
+			target:   &TargetValue{Value: "2024-01-01T00:00:00Z"},
+			input:    "2024-01-01T00:00:00",
+			kind:     KindDateTime,
+			wantBool: false,
+		},
+		{
+			name:     "duration greater",
+			operator: "greaterThan",
+			target:   &TargetValue{Value: "P30D"},
+			input:    "P1Y",
+			kind:     KindDuration,
+			wantBool: true,
+		},
+		{
+			name:     "number equal",
+			operator: "equal",
+			target:   &TargetValue{Value: "3"},
+			input:    "3.0",
+			kind:     KindNumber,
+			wantBool: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluateTarget(tt.operator, tt.target, tt.input, tt.kind)
+			if got != tt.wantBool {
+				t.Errorf("evaluateTarget() got = %v, want %v", got, tt.wantBool)
+			}
+		})
+	}
+}
+
+func TestSQLKinds(t *testing.T) {
+	rule := &Rule{
+		Type:     "group",
+		Operator: "AND",
+		Items: []Rule{
+			{
+				Type:      "attribute",
+				Operator:  "range",
+				Attribute: RuleAttribute{Name: "data.created", Kind: KindDate},
+				Assert:    json.RawMessage(`{"from": "2024-01-01", "to": "2024-12-31"}`),
+			},
+			{
+				Type:      "attribute",
+				Operator:  "lessThan",
+				Attribute: RuleAttribute{Name: "data.published", Kind: KindDateTime},
+				Assert:    json.RawMessage(`{"value": "2024-06-01T12:00:00+02:00"}`),
+			},
+			{
+				Type:      "attribute",
+				Operator:  "greaterThan",
+				Attribute: RuleAttribute{Name: "data.retention", Kind: KindDuration},
+				Assert:    json.RawMessage(`{"value": "P90D"}`),
+			},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	tests := []struct {
+		dialect   Dialect
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			dialect: Postgres,
+			wantWhere: `( "created" BETWEEN CAST($1 AS DATE) AND CAST($2 AS DATE) AND ` +
+				`"published" < CAST($3 AS TIMESTAMPTZ) AND "retention" > CAST($4 AS INTERVAL) )`,
+			wantArgs: []any{"2024-01-01", "2024-12-31", "2024-06-01T12:00:00+02:00", "P90D"},
+		},
+		{
+			dialect: MySQL,
+			wantWhere: "( `created` BETWEEN CAST(? AS DATE) AND CAST(? AS DATE) AND " +
+				"`published` < CAST(? AS DATETIME(6)) AND `retention` > ? )",
+			wantArgs: []any{"2024-01-01", "2024-12-31", "2024-06-01 10:00:00", "7776000"},
+		},
+		{
+			dialect: SQLite,
+			wantWhere: `( "created" BETWEEN date(?) AND date(?) AND ` +
+				`"published" < datetime(?) AND "retention" > ? )`,
+			wantArgs: []any{"2024-01-01", "2024-12-31", "2024-06-01T12:00:00+02:00", "7776000"},
+		},
+		{
+			dialect: SQLServer,
+			wantWhere: `( [created] BETWEEN CAST(@p1 AS DATE) AND CAST(@p2 AS DATE) AND ` +
+				`[published] < CAST(@p3 AS DATETIMEOFFSET) AND [retention] > @p4 )`,
+			wantArgs: []any{"2024-01-01", "2024-12-31", "2024-06-01T12:00:00+02:00", "7776000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			b := &SQLBuilder{Dialect: tt.dialect}
+			where, err := b.Compile(rule)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), tt.wantArgs) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), tt.wantArgs)
+			}
+		})
+	}
+
+	evaluated, err := rule.Evaluate(map[string]string{})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+	wantString := `( data.created BETWEEN DATE '2024-01-01' AND DATE '2024-12-31' AND ` +
+		`data.published < TIMESTAMPTZ '2024-06-01T12:00:00+02:00' AND data.retention > INTERVAL 'P90D' )`
+	if evaluated.Stringer() != wantString {
+		t.Errorf("Stringer() got = >%v<, want >%v<", evaluated.Stringer(), wantString)
+	}
+}
+
+func TestValidateKinds(t *testing.T) {
+	rule := &Rule{
+		Name:      "created",
+		Type:      "attribute",
+		Operator:  "range",
+		Attribute: RuleAttribute{Name: "data.created", Kind: KindDate},
+		Assert:    json.RawMessage(`{"from": "2024-01-01", "to": "31.12.2024"}`),
+	}
+	want := []RuleError{{Name: "created", Code: CodeAssertValueInvalid, Err: "assert value `31.12.2024` is not a valid date"}}
+	if got := Validate(rule); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,348 @@
+package rulejson
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MongoBuilder compiles evaluated rules into MongoDB filter documents, the
+// counterpart of SQLBuilder for collections. Data attributes compile to
+// fields, data.address.city to the nested field address.city. Missing and
+// null fields follow the NULL convention of SQLBuilder.
+type MongoBuilder struct {
+	// Clock fixes the time relative time operators compare against,
+	// defaults to time.Now.
+	Clock func() time.Time
+	// ExtendedJSON renders dates as {"$date": ...} documents so the
+	// filter survives encoding as JSON, otherwise they are time.Time.
+	ExtendedJSON bool
+}
+
+// mongoOperators maps the SQL comparison operators to query operators.
+var mongoOperators = map[string]string{
+	"=":  "$eq",
+	"<>": "$ne",
+	"<":  "$lt",
+	"<=": "$lte",
+	">":  "$gt",
+	">=": "$gte",
+}
+
+// Compile renders rule, usually the result of Evaluate, as a filter
+// document. Rules decided during evaluation match all or no documents.
+func (b *MongoBuilder) Compile(rule *Rule) (map[string]any, error) {
+	if rule.Decision.Decided() {
+		return mongoBool(rule.Decision == True), nil
+	}
+
+	switch rule.Type {
+	case "bool":
+		switch rule.Operator {
+		case "true":
+			return mongoBool(true), nil
+		case "false":
+			return mongoBool(false), nil
+		}
+
+		return nil, fmt.Errorf("rule `%s` has invalid bool value `%s`", rule.Name, rule.Operator)
+	case "attribute":
+		return b.compileTarget(rule)
+	case "comparison":
+		return b.compileComparison(rule)
+	case "group":
+		if rule.Operator == "NOT" {
+			if len(rule.Items) != 1 {
+				return nil, fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+			}
+			item, err := b.Compile(&rule.Items[0])
+			if err != nil {
+				return nil, err
+			}
+
+			// $nor matches documents the item doesn't, like IS NOT TRUE.
+			return map[string]any{"$nor": []any{item}}, nil
+		}
+		items := make([]any, len(rule.Items))
+		for i := range rule.Items {
+			item, err := b.Compile(&rule.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+
+		switch rule.Operator {
+		case "AND":
+			return map[string]any{"$and": items}, nil
+		case "OR":
+			return map[string]any{"$or": items}, nil
+		}
+
+		return nil, fmt.Errorf("rule `%s` has unknown group operator `%s`", rule.Name, rule.Operator)
+	}
+
+	return nil, fmt.Errorf("allowed rule types are `attribute`, `bool`, `comparison` or `group` got: `%s`", rule.Type)
+}
+
+func mongoBool(value bool) map[string]any {
+	return map[string]any{"$expr": value}
+}
+
+// value converts value to the BSON type values of kind are stored as,
+// numbers as doubles, dates as dates and durations as seconds.
+func (b *MongoBuilder) value(value string, kind string) any {
+	parsed, err := parseValue(kind, value)
+	if err != nil {
+		return value
+	}
+	switch v := parsed.(type) {
+	case float64:
+		return v
+	case time.Time:
+		return b.time(v)
+	case time.Duration:
+		return v.Seconds()
+	}
+
+	return value
+}
+
+func (b *MongoBuilder) values(values []string, kind string) []any {
+	converted := make([]any, len(values))
+	for i := range values {
+		converted[i] = b.value(values[i], kind)
+	}
+
+	return converted
+}
+
+func (b *MongoBuilder) time(t time.Time) any {
+	if !b.ExtendedJSON {
+		return t
+	}
+
+	return map[string]any{"$date": formatTime(t.UTC(), KindDateTime)}
+}
+
+func (b *MongoBuilder) now() time.Time {
+	if b.Clock == nil {
+		return time.Now()
+	}
+
+	return b.Clock()
+}
+
+// mongoColumn returns the field of a data attribute. Its segments must
+// not be empty or start with $, the field would be read as an operator or
+// as a path to another field.
+func mongoColumn(rule *Rule, name string) (string, error) {
+	field, err := dataColumn(name)
+	if err != nil {
+		return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+	for _, segment := range strings.Split(field, ".") {
+		if segment == "" || strings.HasPrefix(segment, "$") {
+			return "", fmt.Errorf("rule `%s`: attribute `%s` is not a valid MongoDB field", rule.Name, name)
+		}
+	}
+
+	return field, nil
+}
+
+// mongoField returns a filter on the field of a data attribute.
+func mongoField(rule *Rule, name string, condition any) (map[string]any, error) {
+	field, err := mongoColumn(rule, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{field: condition}, nil
+}
+
+// mongoNotIn matches values that are neither one of values nor null,
+// $nin alone would match missing fields.
+func mongoNotIn(values []any) map[string]any {
+	return map[string]any{"$nin": append(values, nil)}
+}
+
+func mongoRegex(w Wildcard, ignoreCase bool) map[string]any {
+	options := "s"
+	if ignoreCase {
+		options += "i"
+	}
+
+	return map[string]any{"$regex": w.regexPattern(), "$options": options}
+}
+
+func (b *MongoBuilder) compileTarget(rule *Rule) (map[string]any, error) {
+	if rule.ParsedTarget == nil {
+		return nil, fmt.Errorf("rule `%s` has no parsed target, was it validated?", rule.Name)
+	}
+	kind := rule.Attribute.Kind
+
+	var condition any
+	switch target := rule.ParsedTarget.(type) {
+	case *TargetValue:
+		value := b.value(target.Value, kind)
+		switch rule.Operator {
+		case "equal":
+			condition = map[string]any{"$eq": value}
+		case "notEqual":
+			condition = mongoNotIn([]any{value})
+		case "greaterThan":
+			condition = map[string]any{"$gt": value}
+		case "lessThan":
+			condition = map[string]any{"$lt": value}
+		case "isSubstringOf":
+			field, err := mongoColumn(rule, rule.Attribute.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			// the field is a substring of the value, $indexOfCP fails on
+			// fields that aren't strings. $literal keeps a value starting
+			// with $ from being read as a field path.
+			return map[string]any{"$and": []any{
+				map[string]any{field: map[string]any{"$type": "string"}},
+				map[string]any{"$expr": map[string]any{"$gte": []any{
+					map[string]any{"$indexOfCP": []any{map[string]any{"$literal": target.Value}, "$" + field}}, 0,
+				}}},
+			}}, nil
+		case "startsWith":
+			condition = mongoRegex(Wildcard{{literal: target.Value}, {kind: wildcardAny}}, false)
+		case "endsWith":
+			condition = mongoRegex(Wildcard{{kind: wildcardAny}, {literal: target.Value}}, false)
+		default:
+			return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+		}
+	case *TargetValues:
+		values := b.values(target.Values, kind)
+		switch rule.Operator {
+		case "in", "anyOf", "intersects":
+			// $in matches a single value field as well as an array with
+			// one of the values.
+			condition = map[string]any{"$in": values}
+		case "notIn":
+			condition = mongoNotIn(values)
+		case "allOf":
+			// $all on a single value field holds if it equals all values.
+			condition = map[string]any{"$all": values}
+		default:
+			return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+		}
+	case *TargetRange:
+		condition = map[string]any{"$gte": b.value(target.From, kind), "$lte": b.value(target.To, kind)}
+	case *TargetWildcard:
+		w, err := target.Wildcard()
+		if err != nil {
+			return nil, fmt.Errorf("rule `%s`: invalid wildcard pattern: %w", rule.Name, err)
+		}
+		condition = mongoRegex(w, target.IgnoreCase)
+	case *TargetNull:
+		condition = map[string]any{"$eq": nil}
+	case *TargetRelative:
+		threshold := target.threshold(rule.Operator, kind, b.now())
+		condition = map[string]any{mongoOperators[relativeOperators[rule.Operator]]: b.time(threshold)}
+	default:
+		return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+	}
+
+	return mongoField(rule, rule.Attribute.Name, condition)
+}
+
+func (b *MongoBuilder) compileComparison(rule *Rule) (map[string]any, error) {
+	if len(rule.Attributes) != 2 {
+		return nil, fmt.Errorf("rule `%s` with type `comparison` must have exactly two attributes", rule.Name)
+	}
+
+	left, right := rule.Attributes[0], rule.Attributes[1]
+	operator := rule.Operator
+	if operator == "contains" {
+		left, right, operator = right, left, "in"
+	}
+
+	leftValues, leftResolved := rule.Resolved[left.Name]
+	rightValues, rightResolved := rule.Resolved[right.Name]
+	switch {
+	case leftResolved && rightResolved:
+		return mongoBool(evaluateComparison(operator, leftValues, rightValues, kindOf(left))), nil
+	case operator == "in" && leftResolved:
+		// the field, or one of its values, is one of the values.
+		return b.compareList(rule, right.Name, "$eq", leftValues, left.Kind)
+	case operator == "in" && rightResolved:
+		return b.compareList(rule, left.Name, "$eq", rightValues, right.Kind)
+	case operator == "in" && right.Multi:
+		return mongoCompareFields(rule, left.Name, right.Name, func(l string, r string) any {
+			return map[string]any{"$in": []any{l, r}}
+		})
+	case operator == "in":
+		operator = "equal"
+	}
+
+	sqlOp, ok := comparisonOperators[operator]
+	if !ok {
+		return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+	}
+	op := mongoOperators[sqlOp]
+	switch {
+	case leftResolved:
+		return b.compareList(rule, right.Name, mongoOperators[flipOperator(sqlOp)], leftValues, left.Kind)
+	case rightResolved:
+		return b.compareList(rule, left.Name, op, rightValues, right.Kind)
+	}
+
+	return mongoCompareFields(rule, left.Name, right.Name, func(l string, r string) any {
+		return map[string]any{op: []any{l, r}}
+	})
+}
+
+// compareList compares the field of name against resolved values, it holds
+// if it holds for one of them. Like in SQL, notEqual must hold for all.
+func (b *MongoBuilder) compareList(rule *Rule, name string, op string, values []string, kind string) (map[string]any, error) {
+	if len(values) == 0 {
+		return mongoBool(op == "$ne"), nil
+	}
+
+	converted := b.values(values, kind)
+	switch op {
+	case "$eq":
+		return mongoField(rule, name, map[string]any{"$in": converted})
+	case "$ne":
+		return mongoField(rule, name, mongoNotIn(converted))
+	}
+	if len(converted) == 1 {
+		return mongoField(rule, name, map[string]any{op: converted[0]})
+	}
+
+	conditions := make([]any, len(converted))
+	for i := range converted {
+		condition, err := mongoField(rule, name, map[string]any{op: converted[i]})
+		if err != nil {
+			return nil, err
+		}
+		conditions[i] = condition
+	}
+
+	return map[string]any{"$or": conditions}, nil
+}
+
+// mongoCompareFields compares two fields with an aggregation expression.
+// Expressions order null before all values, fields must not be null to
+// match like in SQL.
+func mongoCompareFields(rule *Rule, left string, right string, expr func(l string, r string) any) (map[string]any, error) {
+	leftField, err := mongoColumn(rule, left)
+	if err != nil {
+		return nil, err
+	}
+	rightField, err := mongoColumn(rule, right)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"$and": []any{
+		map[string]any{leftField: map[string]any{"$ne": nil}},
+		map[string]any{rightField: map[string]any{"$ne": nil}},
+		map[string]any{"$expr": expr("$"+leftField, "$"+rightField)},
+	}}, nil
+}
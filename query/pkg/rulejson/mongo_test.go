@@ -0,0 +1,241 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMongoBuilder(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	attribute := func(name string, kind string, operator string, assert string) *Rule {
+		rule := &Rule{
+			Name:      "rule",
+			Type:      "attribute",
+			Operator:  operator,
+			Attribute: RuleAttribute{Name: name, Kind: kind},
+		}
+		if assert != "" {
+			rule.Assert = json.RawMessage(assert)
+		}
+
+		return rule
+	}
+
+	tests := []struct {
+		name  string
+		rule  *Rule
+		input Input
+		want  string
+	}{
+		{
+			name: "equal",
+			rule: attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+			want: `{"city":{"$eq":"Hamburg"}}`,
+		},
+		{
+			name: "nested field",
+			rule: attribute("data.address.city", "", "equal", `{"value": "Hamburg"}`),
+			want: `{"address.city":{"$eq":"Hamburg"}}`,
+		},
+		{
+			name: "notEqual excludes null",
+			rule: attribute("data.city", "", "notEqual", `{"value": "Hamburg"}`),
+			want: `{"city":{"$nin":["Hamburg",null]}}`,
+		},
+		{
+			name: "number",
+			rule: attribute("data.age", KindNumber, "greaterThan", `{"value": "18"}`),
+			want: `{"age":{"$gt":18}}`,
+		},
+		{
+			name: "range",
+			rule: attribute("data.age", KindNumber, "range", `{"from": "18", "to": "65"}`),
+			want: `{"age":{"$gte":18,"$lte":65}}`,
+		},
+		{
+			name: "in",
+			rule: attribute("data.city", "", "in", `{"values": ["Hamburg", "Berlin"]}`),
+			want: `{"city":{"$in":["Hamburg","Berlin"]}}`,
+		},
+		{
+			name: "allOf",
+			rule: attribute("data.tags", "", "allOf", `{"values": ["red", "blue"]}`),
+			want: `{"tags":{"$all":["red","blue"]}}`,
+		},
+		{
+			name: "startsWith escapes",
+			rule: attribute("data.path", "", "startsWith", `{"value": "a.b"}`),
+			want: `{"path":{"$options":"s","$regex":"^a\\.b.*\\z"}}`,
+		},
+		{
+			name: "wildcard",
+			rule: attribute("data.name", "", "matchesWildcard", `{"value": "J?hn*", "ignoreCase": true}`),
+			want: `{"name":{"$options":"si","$regex":"^j.hn.*\\z"}}`,
+		},
+		{
+			name: "isSubstringOf",
+			rule: attribute("data.code", "", "isSubstringOf", `{"value": "ABCDEF"}`),
+			want: `{"$and":[{"code":{"$type":"string"}},{"$expr":{"$gte":[{"$indexOfCP":[{"$literal":"ABCDEF"},"$code"]},0]}}]}`,
+		},
+		{
+			name: "isSubstringOf a field path",
+			rule: attribute("data.code", "", "isSubstringOf", `{"value": "$secret"}`),
+			want: `{"$and":[{"code":{"$type":"string"}},{"$expr":{"$gte":[{"$indexOfCP":[{"$literal":"$secret"},"$code"]},0]}}]}`,
+		},
+		{
+			name: "isNull",
+			rule: attribute("data.city", "", "isNull", ""),
+			want: `{"city":{"$eq":null}}`,
+		},
+		{
+			name: "withinLast",
+			rule: attribute("data.created", KindDate, "withinLast", `{"duration": "P7D"}`),
+			want: `{"created":{"$gte":{"$date":"2024-06-08T00:00:00Z"}}}`,
+		},
+		{
+			name: "group and not",
+			rule: &Rule{Type: "group", Operator: "OR", Items: []Rule{
+				*attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+				{Type: "group", Operator: "NOT", Items: []Rule{*attribute("data.city", "", "isNull", "")}},
+			}},
+			want: `{"$or":[{"city":{"$eq":"Hamburg"}},{"$nor":[{"city":{"$eq":null}}]}]}`,
+		},
+		{
+			name: "decided",
+			rule: &Rule{Type: "group", Operator: "AND", Items: []Rule{
+				*attribute("user.role", "", "equal", `{"value": "admin"}`),
+				*attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+			}},
+			input: Input{"user.role": {"staff"}},
+			want:  `{"$expr":false}`,
+		},
+		{
+			name: "comparison with user",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "lt",
+				Attributes: []RuleAttribute{{Name: "user.level", Kind: KindNumber}, {Name: "data.level", Kind: KindNumber}},
+			},
+			input: Input{"user.level": {"3"}},
+			want:  `{"level":{"$gt":3}}`,
+		},
+		{
+			name: "comparison with user list",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "notEqual",
+				Attributes: []RuleAttribute{{Name: "data.department"}, {Name: "user.department"}},
+			},
+			input: Input{"user.department": {"sales", "it"}},
+			want:  `{"department":{"$nin":["sales","it",null]}}`,
+		},
+		{
+			name: "comparison in multi-valued field",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "in",
+				Attributes: []RuleAttribute{{Name: "user.groups"}, {Name: "data.groups", Multi: true}},
+			},
+			input: Input{"user.groups": {"sales", "it"}},
+			want:  `{"groups":{"$in":["sales","it"]}}`,
+		},
+		{
+			name: "comparison of fields",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "lte",
+				Attributes: []RuleAttribute{{Name: "data.start", Kind: KindNumber}, {Name: "data.end", Kind: KindNumber}},
+			},
+			want: `{"$and":[{"start":{"$ne":null}},{"end":{"$ne":null}},{"$expr":{"$lte":["$start","$end"]}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			evaluated, err := tt.rule.EvaluateInput(tt.input)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			b := &MongoBuilder{Clock: func() time.Time { return now }, ExtendedJSON: true}
+			filter, err := b.Compile(evaluated)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := json.Marshal(filter)
+			if err != nil {
+				t.Fatalf("failed to marshal filter: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Compile() got = >%s<, want >%v<", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoBuilderTime(t *testing.T) {
+	rule := &Rule{
+		Type:      "attribute",
+		Operator:  "greaterThan",
+		Attribute: RuleAttribute{Name: "data.created", Kind: KindDateTime},
+		Assert:    json.RawMessage(`{"value": "2024-06-15T12:00:00+02:00"}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	filter, err := (&MongoBuilder{}).Compile(rule)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	//nolint:forcetypeassert
+	got := filter["created"].(map[string]any)["$gt"]
+	if v, ok := got.(time.Time); !ok || !v.Equal(time.Date(2024, 6, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Compile() got = >%v<, want a time.Time", got)
+	}
+}
+
+func TestMongoBuilderUserAttribute(t *testing.T) {
+	rule := &Rule{
+		Name:      "role",
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "user.role"},
+		Assert:    json.RawMessage(`{"value": "admin"}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	if _, err := (&MongoBuilder{}).Compile(rule); err == nil {
+		t.Errorf("Compile() error = nil, want error for a user attribute")
+	}
+}
+
+func TestMongoBuilderInvalidField(t *testing.T) {
+	for _, name := range []string{"data.$where", "data.address.$ne", "data.address..city", "data.city."} {
+		t.Run(name, func(t *testing.T) {
+			rule := &Rule{
+				Name:      "field",
+				Type:      "attribute",
+				Operator:  "isSubstringOf",
+				Attribute: RuleAttribute{Name: name},
+				Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+			}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			if _, err := (&MongoBuilder{}).Compile(rule); err == nil {
+				t.Errorf("Compile() error = nil, want error for field of %s", name)
+			}
+
+			rule.Operator = "equal"
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			if _, err := (&MongoBuilder{}).Compile(rule); err == nil {
+				t.Errorf("Compile() error = nil, want error for field of %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValuesUnmarshal(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Values
+		wantErr bool
+	}{
+		{data: `"sales"`, want: Values{"sales"}},
+		{data: `["sales", "marketing"]`, want: Values{"sales", "marketing"}},
+		{data: `[]`, want: Values{}},
+		{data: `42`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var got Values
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMultiValued(t *testing.T) {
+	input := Input{
+		"user.groups": {"sales", "marketing"},
+		"user.none":   {},
+	}
+	tests := []struct {
+		name     string
+		operator string
+		attr     string
+		assert   string
+		want     string
+	}{
+		{name: "anyOf", operator: "anyOf", attr: "user.groups", assert: `{"values": ["marketing", "hr"]}`, want: "true"},
+		{name: "anyOf none", operator: "anyOf", attr: "user.groups", assert: `{"values": ["hr"]}`, want: "false"},
+		{name: "intersects", operator: "intersects", attr: "user.groups", assert: `{"values": ["sales"]}`, want: "true"},
+		{name: "allOf", operator: "allOf", attr: "user.groups", assert: `{"values": ["sales", "marketing"]}`, want: "true"},
+		{name: "allOf missing", operator: "allOf", attr: "user.groups", assert: `{"values": ["sales", "hr"]}`, want: "false"},
+		{name: "equal any", operator: "equal", attr: "user.groups", assert: `{"value": "sales"}`, want: "true"},
+		{name: "notEqual all", operator: "notEqual", attr: "user.groups", assert: `{"value": "sales"}`, want: "false"},
+		{name: "notIn all", operator: "notIn", attr: "user.groups", assert: `{"values": ["hr", "it"]}`, want: "true"},
+		{name: "isNull empty", operator: "isNull", attr: "user.none", want: "true"},
+		{name: "anyOf empty", operator: "anyOf", attr: "user.none", assert: `{"values": ["hr"]}`, want: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{
+				Type:      "attribute",
+				Operator:  tt.operator,
+				Attribute: RuleAttribute{Name: tt.attr},
+			}
+			if tt.assert != "" {
+				rule.Assert = json.RawMessage(tt.assert)
+			}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			evaluated, err := rule.EvaluateInput(input)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			if evaluated.Decision.String() != tt.want {
+				t.Errorf("EvaluateInput() got = >%v<, want >%v<", evaluated.Decision.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLMultiValued(t *testing.T) {
+	rule := &Rule{
+		Type:     "group",
+		Operator: "AND",
+		Items: []Rule{
+			{
+				Type:      "attribute",
+				Operator:  "intersects",
+				Attribute: RuleAttribute{Name: "data.tags", Multi: true},
+				Assert:    json.RawMessage(`{"values": ["a", "b"]}`),
+			},
+			{
+				Type:      "attribute",
+				Operator:  "allOf",
+				Attribute: RuleAttribute{Name: "data.tags", Multi: true},
+				Assert:    json.RawMessage(`{"values": ["c", "d"]}`),
+			},
+			{
+				Type:      "attribute",
+				Operator:  "anyOf",
+				Attribute: RuleAttribute{Name: "data.region"},
+				Assert:    json.RawMessage(`{"values": ["eu"]}`),
+			},
+			{
+				Type:       "comparison",
+				Operator:   "equal",
+				Attributes: []RuleAttribute{{Name: "user.groups"}, {Name: "data.group"}},
+			},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	evaluated, err := rule.EvaluateInput(Input{"user.groups": {"sales", "marketing"}})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+
+	tests := []struct {
+		dialect   Dialect
+		wantWhere string
+	}{
+		{
+			dialect: Postgres,
+			wantWhere: `( ( $1 = ANY("tags") OR $2 = ANY("tags") ) AND ` +
+				`( $3 = ANY("tags") AND $4 = ANY("tags") ) AND ` +
+				`"region" IN ($5) AND "group" IN ($6, $7) )`,
+		},
+		{
+			dialect: MySQL,
+			wantWhere: "( JSON_OVERLAPS(`tags`, JSON_ARRAY(?, ?)) AND JSON_CONTAINS(`tags`, JSON_ARRAY(?, ?)) AND " +
+				"`region` COLLATE utf8mb4_bin IN (?) AND `group` COLLATE utf8mb4_bin IN (?, ?) )",
+		},
+		{
+			dialect: SQLite,
+			wantWhere: `( EXISTS (SELECT 1 FROM json_each("tags") WHERE value IN (?, ?)) AND ` +
+				`( EXISTS (SELECT 1 FROM json_each("tags") WHERE value IN (?)) AND ` +
+				`EXISTS (SELECT 1 FROM json_each("tags") WHERE value IN (?)) ) AND ` +
+				`"region" IN (?) AND "group" IN (?, ?) )`,
+		},
+		{
+			dialect: SQLServer,
+			wantWhere: `( EXISTS (SELECT 1 FROM OPENJSON([tags]) WHERE value IN (@p1, @p2)) AND ` +
+				`( EXISTS (SELECT 1 FROM OPENJSON([tags]) WHERE value IN (@p3)) AND ` +
+				`EXISTS (SELECT 1 FROM OPENJSON([tags]) WHERE value IN (@p4)) ) AND ` +
+				`[region] COLLATE Latin1_General_BIN2 IN (@p5) AND [group] COLLATE Latin1_General_BIN2 IN (@p6, @p7) )`,
+		},
+	}
+	wantArgs := []any{"a", "b", "c", "d", "eu", "sales", "marketing"}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			b := &SQLBuilder{Dialect: tt.dialect}
+			where, err := b.Compile(evaluated)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), wantArgs) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), wantArgs)
+			}
+		})
+	}
+
+	wantString := `( ( 'a' = ANY(data.tags) OR 'b' = ANY(data.tags) ) AND ` +
+		`( 'c' = ANY(data.tags) AND 'd' = ANY(data.tags) ) AND ` +
+		`data.region IN ('eu') AND data.group IN ('sales', 'marketing') )`
+	if evaluated.Stringer() != wantString {
+		t.Errorf("Stringer() got = >%v<, want >%v<", evaluated.Stringer(), wantString)
+	}
+}
+
+func TestSQLMultiValuedNumber(t *testing.T) {
+	// the values take the element type of the column, an int[] or numeric[]
+	// array, instead of forming a text[].
+	rule := &Rule{
+		Type:     "group",
+		Operator: "AND",
+		Items: []Rule{
+			{
+				Type:      "attribute",
+				Operator:  "anyOf",
+				Attribute: RuleAttribute{Name: "data.levels", Kind: KindNumber, Multi: true},
+				Assert:    json.RawMessage(`{"values": ["1", "2.5"]}`),
+			},
+			{
+				Type:      "attribute",
+				Operator:  "allOf",
+				Attribute: RuleAttribute{Name: "data.levels", Kind: KindNumber, Multi: true},
+				Assert:    json.RawMessage(`{"values": ["3"]}`),
+			},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	b := &SQLBuilder{}
+	where, err := b.Compile(rule)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	want := `( ( $1 = ANY("levels") OR $2 = ANY("levels") ) AND $3 = ANY("levels") )`
+	if where != want {
+		t.Errorf("Compile() got = >%v<, want >%v<", where, want)
+	}
+	if wantArgs := []any{"1", "2.5", "3"}; !reflect.DeepEqual(b.Args(), wantArgs) {
+		t.Errorf("Args() got = %v, want %v", b.Args(), wantArgs)
+	}
+
+	wantString := `( ( 1 = ANY(data.levels) OR 2.5 = ANY(data.levels) ) AND 3 = ANY(data.levels) )`
+	if rule.Stringer() != wantString {
+		t.Errorf("Stringer() got = >%v<, want >%v<", rule.Stringer(), wantString)
+	}
+}
+
+func TestSQLAllOfSingleValued(t *testing.T) {
+	rule := &Rule{
+		Type:      "attribute",
+		Operator:  "allOf",
+		Attribute: RuleAttribute{Name: "data.region"},
+		Assert:    json.RawMessage(`{"values": ["eu", "us"]}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	b := &SQLBuilder{}
+	where, err := b.Compile(rule)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	want := `( "region" = $1 AND "region" = $2 )`
+	if where != want {
+		t.Errorf("Compile() got = >%v<, want >%v<", where, want)
+	}
+}
+
+func TestValidateMultiValued(t *testing.T) {
+	rule := &Rule{
+		Name:      "tags",
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "data.tags", Multi: true},
+		Assert:    json.RawMessage(`{"value": "a"}`),
+	}
+	want := []RuleError{{Name: "tags", Code: CodeMultiOperatorInvalid, Err: "rule with multi-valued attribute must have operator `anyOf`, `allOf`, `intersects` or `isNull`"}}
+	if got := Validate(rule); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() got = %v, want %v", got, want)
+	}
+}
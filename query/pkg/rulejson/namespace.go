@@ -0,0 +1,161 @@
+package rulejson
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Attribute namespaces. Attribute names are prefixed with their namespace
+// and a dot, like user.city. User and env attributes are resolved from the
+// input during evaluation, only data attributes, the columns of the
+// queried table, are compiled to a query.
+const (
+	NamespaceUser = "user"
+	NamespaceData = "data"
+	NamespaceEnv  = "env"
+)
+
+// namespaceOf returns the namespace of an attribute name and the name
+// within it, ok is false if the name has no known namespace.
+func namespaceOf(name string) (namespace string, column string, ok bool) {
+	namespace, column, found := strings.Cut(name, ".")
+	if !found || column == "" {
+		return "", "", false
+	}
+	switch namespace {
+	case NamespaceUser, NamespaceData, NamespaceEnv:
+		return namespace, column, true
+	}
+
+	return "", "", false
+}
+
+func validateNamespace(rule *Rule, name string, errs *[]RuleError) {
+	if name == "" {
+		return
+	}
+	if _, _, ok := namespaceOf(name); !ok {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeAttributeNamespace,
+			Err:  "attribute `" + name + "` must be namespaced with `user.`, `data.` or `env.`",
+		})
+	}
+}
+
+// ErrMissingAttribute is returned by Evaluate with MissingError if a user
+// or env attribute is not part of the input.
+var ErrMissingAttribute = errors.New("missing attribute")
+
+// MissingPolicy decides how Evaluate treats user and env attributes that
+// are not part of the input.
+type MissingPolicy int
+
+const (
+	// MissingDeny decides the rules referring to the attribute so they
+	// can't grant access: they are false, or true if negated by NOT. In a
+	// policy with effect deny it is the other way round, the deny applies.
+	MissingDeny MissingPolicy = iota
+	// MissingError fails the evaluation with ErrMissingAttribute.
+	MissingError
+	// MissingNull treats the attribute as null, only isNull holds for it.
+	MissingNull
+)
+
+// MissingPolicyByName returns the policy named deny, error or null. An
+// empty name selects MissingDeny.
+func MissingPolicyByName(name string) (MissingPolicy, error) {
+	switch name {
+	case "", "deny":
+		return MissingDeny, nil
+	case "error":
+		return MissingError, nil
+	case "null":
+		return MissingNull, nil
+	}
+
+	return MissingDeny, fmt.Errorf("unknown missing attribute policy `%s`, must be `deny`, `error` or `null`", name)
+}
+
+// WithMissing sets how attributes missing from the input are treated,
+// defaults to MissingDeny.
+func WithMissing(policy MissingPolicy) EvaluateOption {
+	return func(ev *evaluation) {
+		ev.missing = policy
+	}
+}
+
+// resolve looks up the values of attr in the input. Data attributes that
+// are not part of the input are left for SQL, for the other namespaces
+// the missing policy applies: ok is false and decided holds the result of
+// the rule unless the policy fails the evaluation. decided is Unevaluated
+// if the attribute doesn't decide the rule.
+func (ev *evaluation) resolve(rule *Rule, name string) (values []string, ok bool, decided Decision, err error) {
+	if values, ok := ev.input[name]; ok {
+		return values, true, Unevaluated, nil
+	}
+	if namespace, _, _ := namespaceOf(name); namespace == NamespaceData {
+		return nil, false, Unevaluated, nil
+	}
+
+	switch ev.missing {
+	case MissingError:
+		return nil, false, Unevaluated, fmt.Errorf("rule `%s`: %w `%s`", rule.Name, ErrMissingAttribute, name)
+	case MissingNull:
+		if rule.Type == "attribute" && rule.Operator == "isNull" {
+			return nil, false, True, nil
+		}
+
+		return nil, false, False, nil
+	}
+
+	return nil, false, decisionFor(ev.missingValue), nil
+}
+
+// dataColumn returns the column of a data attribute, other namespaces can't
+// be compiled to a query.
+func dataColumn(name string) (string, error) {
+	namespace, column, ok := namespaceOf(name)
+	if !ok || namespace != NamespaceData {
+		return "", fmt.Errorf("attribute `%s` is not a data attribute and can't be compiled to a query", name)
+	}
+
+	return column, nil
+}
+
+// identifier matches the segments of names that need no quoting in
+// policy languages like Rego and Cedar.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// inputAttributes returns the sorted names of the user and env attributes
+// rule refers to.
+func inputAttributes(rule *Rule) []string {
+	seen := map[string]bool{}
+	var walk func(rule *Rule)
+	walk = func(rule *Rule) {
+		attrs := rule.Attributes
+		if rule.Type == "attribute" {
+			attrs = []RuleAttribute{rule.Attribute}
+		}
+		for _, attr := range attrs {
+			if namespace, _, ok := namespaceOf(attr.Name); ok && namespace != NamespaceData {
+				seen[attr.Name] = true
+			}
+		}
+		for i := range rule.Items {
+			walk(&rule.Items[i])
+		}
+	}
+	walk(rule)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
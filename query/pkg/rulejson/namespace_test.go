@@ -0,0 +1,229 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestEvaluateMissing(t *testing.T) {
+	rule := &Rule{
+		Type:     "group",
+		Operator: "OR",
+		Items: []Rule{
+			{
+				Type:      "attribute",
+				Operator:  "equal",
+				Attribute: RuleAttribute{Name: "data.city"},
+				Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+			},
+			{
+				Type:     "group",
+				Operator: "NOT",
+				Items: []Rule{
+					{
+						Name:      "city",
+						Type:      "attribute",
+						Operator:  "equal",
+						Attribute: RuleAttribute{Name: "user.city"},
+						Assert:    json.RawMessage(`{"value": "Berlin"}`),
+					},
+				},
+			},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		policy     MissingPolicy
+		wantString string
+		wantErr    error
+	}{
+		{name: "deny", policy: MissingDeny, wantString: `( data.city = 'Hamburg' OR false )`},
+		{name: "null", policy: MissingNull, wantString: `true`},
+		{name: "error", policy: MissingError, wantErr: ErrMissingAttribute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluated, err := rule.EvaluateInput(Input{}, WithMissing(tt.policy))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EvaluateInput() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if evaluated.Stringer() != tt.wantString {
+				t.Errorf("EvaluateInput() got = >%v<, want >%v<", evaluated.Stringer(), tt.wantString)
+			}
+		})
+	}
+}
+
+func TestEvaluateMissingComparison(t *testing.T) {
+	rule := comparisonRule("equal", RuleAttribute{Name: "data.city"}, RuleAttribute{Name: "env.city"})
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	evaluated, err := rule.EvaluateInput(Input{}, WithMissing(MissingNull))
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+	if evaluated.Stringer() != "false" {
+		t.Errorf("EvaluateInput() got = >%v<, want >false<", evaluated.Stringer())
+	}
+
+	evaluated, err = rule.EvaluateInput(Input{"env.city": {"Hamburg"}})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+	if evaluated.Stringer() != "data.city = 'Hamburg'" {
+		t.Errorf("EvaluateInput() got = >%v<, want >data.city = 'Hamburg'<", evaluated.Stringer())
+	}
+}
+
+func TestEvaluateMissingOrder(t *testing.T) {
+	// a missing attribute only decides the rules referring to it, the
+	// order of the items doesn't change the result.
+	equal := func(name string, value string) Rule {
+		return Rule{
+			Type:      "attribute",
+			Operator:  "equal",
+			Attribute: RuleAttribute{Name: name},
+			Assert:    json.RawMessage(`{"value": "` + value + `"}`),
+		}
+	}
+	not := func(item Rule) Rule {
+		return Rule{Type: "group", Operator: "NOT", Items: []Rule{item}}
+	}
+	input := Input{"user.a": {"x"}}
+	tests := []struct {
+		name     string
+		effect   string
+		operator string
+		items    []Rule
+		want     string
+	}{
+		{name: "or", operator: "OR", items: []Rule{equal("user.a", "x"), equal("user.b", "y")}, want: "true"},
+		{name: "or swapped", operator: "OR", items: []Rule{equal("user.b", "y"), equal("user.a", "x")}, want: "true"},
+		{name: "and not", operator: "AND", items: []Rule{equal("user.a", "x"), not(equal("user.b", "y"))}, want: "false"},
+		{name: "and not swapped", operator: "AND", items: []Rule{not(equal("user.b", "y")), equal("user.a", "x")}, want: "false"},
+		{name: "deny or", effect: EffectDeny, operator: "OR", items: []Rule{equal("user.a", "z"), equal("user.b", "y")}, want: "true"},
+		{name: "deny or swapped", effect: EffectDeny, operator: "OR", items: []Rule{equal("user.b", "y"), equal("user.a", "z")}, want: "true"},
+		{name: "deny or not", effect: EffectDeny, operator: "OR", items: []Rule{not(equal("user.b", "y")), equal("user.a", "z")}, want: "true"},
+		{name: "deny and", effect: EffectDeny, operator: "AND", items: []Rule{equal("user.b", "y"), equal("user.a", "z")}, want: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{Type: "group", Operator: tt.operator, Items: tt.items, Effect: tt.effect}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+
+			evaluated, err := rule.EvaluateInput(input)
+			if err != nil {
+				t.Fatalf("EvaluateInput() error = %v", err)
+			}
+			if evaluated.Decision.String() != tt.want {
+				t.Errorf("EvaluateInput() got = %v, want %v", evaluated.Stringer(), tt.want)
+			}
+
+			holds, err := rule.EvaluateRow(input, map[string]any{})
+			if err != nil {
+				t.Fatalf("EvaluateRow() error = %v", err)
+			}
+			if strconv.FormatBool(holds) != tt.want {
+				t.Errorf("EvaluateRow() got = %t, want %v", holds, tt.want)
+			}
+
+			compiled, errs := Compile(rule)
+			if len(errs) != 0 {
+				t.Fatalf("Compile() errors = %v", errs)
+			}
+			result, err := compiled.Evaluate(input)
+			if err != nil {
+				t.Fatalf("CompiledPolicy.Evaluate() error = %v", err)
+			}
+			if result.Decision.String() != tt.want {
+				t.Errorf("CompiledPolicy.Evaluate() got = %v, want %v", result.Stringer(), tt.want)
+			}
+
+			trace, err := rule.Explain(input)
+			if err != nil {
+				t.Fatalf("Explain() error = %v", err)
+			}
+			if trace.Outcome != tt.want {
+				t.Errorf("Explain() got outcome = %v, want %v", trace.Outcome, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingPolicyByName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    MissingPolicy
+		wantErr bool
+	}{
+		{name: "", want: MissingDeny},
+		{name: "deny", want: MissingDeny},
+		{name: "error", want: MissingError},
+		{name: "null", want: MissingNull},
+		{name: "ignore", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MissingPolicyByName(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MissingPolicyByName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MissingPolicyByName() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	rule := &Rule{
+		Name:      "city",
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "city"},
+		Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+	}
+	want := []RuleError{{Name: "city", Code: CodeAttributeNamespace, Err: "attribute `city` must be namespaced with `user.`, `data.` or `env.`"}}
+	if got := Validate(rule); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() got = %v, want %v", got, want)
+	}
+}
+
+func TestSQLOnlyDataAttributes(t *testing.T) {
+	rule := &Rule{
+		Name:      "city",
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "user.city"},
+		Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	if _, err := (&SQLBuilder{}).Compile(rule); err == nil {
+		t.Errorf("Compile() expected error for a user attribute")
+	}
+
+	rule.Attribute.Name = "data.city"
+	b := &SQLBuilder{Table: "orders"}
+	where, err := b.Compile(rule)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if want := `"orders"."city" = $1`; where != want {
+		t.Errorf("Compile() got = >%v<, want >%v<", where, want)
+	}
+}
@@ -0,0 +1,439 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RegoExporter translates rules into Open Policy Agent modules in Rego v1
+// syntax. The module defines allow, which holds for an input document
+// like {"user": {...}, "data": {...}, "env": {...}} if the rule does.
+// Attributes are looked up by their name in the object of their
+// namespace, user.address.city at input.user.address.city. Values have
+// their JSON type: numbers are numbers, dates and datetimes strings in
+// their ISO-8601 form and multi-valued attributes arrays.
+//
+// A policy with effect deny defines deny instead of allow, modules combine
+// like DenyOverrides with allow and not deny. A missing user or env
+// attribute decides the rules reading it like MissingDeny, missing data
+// attributes follow the NULL convention of SQLBuilder.
+type RegoExporter struct {
+	// Package is the package of the module, defaults to rulejson.
+	Package string
+}
+
+// regoModule collects the helper rules of a module, rules is indexed like
+// the helpers are numbered.
+type regoModule struct {
+	rules []string
+}
+
+// Export renders rule, which must be validated, as a Rego module.
+// Residuals with user values substituted into comparisons and durations,
+// which Rego can't parse, are reported as errors.
+func (e *RegoExporter) Export(rule *Rule) (string, error) {
+	m := &regoModule{}
+	body, err := m.body(rule, rule.Effect == EffectDeny)
+	if err != nil {
+		return "", err
+	}
+
+	pkg := e.Package
+	if pkg == "" {
+		pkg = "rulejson"
+	}
+
+	name := "allow"
+	if rule.Effect == EffectDeny {
+		name = "deny"
+	}
+
+	var b strings.Builder
+	b.WriteString("package " + pkg + "\n\nimport rego.v1\n\ndefault " + name + " := false\n\n")
+	b.WriteString(regoRule(name, body))
+	for i := range m.rules {
+		b.WriteString("\n" + m.rules[i])
+	}
+
+	return b.String(), nil
+}
+
+func regoRule(name string, body []string) string {
+	return name + " if {\n\t" + strings.Join(body, "\n\t") + "\n}\n"
+}
+
+// body returns the expressions of a rule body that holds if rule does.
+// AND groups are inlined, OR and NOT groups need helper rules. missing is
+// the value of the rules reading a missing user or env attribute, like
+// evaluation.missingValue.
+func (m *regoModule) body(rule *Rule, missing bool) ([]string, error) {
+	if rule.Decision.Decided() {
+		return []string{strconv.FormatBool(rule.Decision == True)}, nil
+	}
+
+	switch rule.Type {
+	case "bool":
+		switch rule.Operator {
+		case "true", "false":
+			return []string{rule.Operator}, nil
+		}
+
+		return nil, fmt.Errorf("rule `%s` has invalid bool value `%s`", rule.Name, rule.Operator)
+	case "attribute":
+		body, err := regoTarget(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		return m.guard(rule, body, missing), nil
+	case "comparison":
+		body, err := regoComparison(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		return m.guard(rule, body, missing), nil
+	case "group":
+		switch rule.Operator {
+		case "AND":
+			var body []string
+			for i := range rule.Items {
+				item, err := m.body(&rule.Items[i], missing)
+				if err != nil {
+					return nil, err
+				}
+				body = append(body, item...)
+			}
+
+			return body, nil
+		case "OR":
+			// every item is a definition of the helper, it holds if one of
+			// them does.
+			name, slot := m.helper()
+			for i := range rule.Items {
+				item, err := m.body(&rule.Items[i], missing)
+				if err != nil {
+					return nil, err
+				}
+				if i > 0 {
+					m.rules[slot] += "\n"
+				}
+				m.rules[slot] += regoRule(name, item)
+			}
+
+			return []string{name}, nil
+		case "NOT":
+			if len(rule.Items) != 1 {
+				return nil, fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+			}
+			name, slot := m.helper()
+			item, err := m.body(&rule.Items[0], !missing)
+			if err != nil {
+				return nil, err
+			}
+			m.rules[slot] = regoRule(name, item)
+
+			// not holds if the item is undefined, like IS NOT TRUE.
+			return []string{"not " + name}, nil
+		}
+
+		return nil, fmt.Errorf("rule `%s` has unknown group operator `%s`", rule.Name, rule.Operator)
+	}
+
+	return nil, fmt.Errorf("allowed rule types are `attribute`, `bool`, `comparison` or `group` got: `%s`", rule.Type)
+}
+
+// guard returns the body of a leaf rule, which holds like missing if one
+// of its user or env attributes is missing and like body otherwise.
+func (m *regoModule) guard(rule *Rule, body []string, missing bool) []string {
+	attrs := inputAttributes(rule)
+	if len(attrs) == 0 {
+		return body
+	}
+
+	if !missing {
+		guards := make([]string, len(attrs))
+		for i := range attrs {
+			namespace, column, _ := namespaceOf(attrs[i])
+			guards[i] = regoRef(namespace, column) + " != null"
+		}
+
+		return append(guards, body...)
+	}
+
+	// the helper holds if an attribute is missing or the body does.
+	name, slot := m.helper()
+	for _, attr := range attrs {
+		namespace, column, _ := namespaceOf(attr)
+		m.rules[slot] += regoRule(name, []string{regoMissing(namespace, column)}) + "\n"
+	}
+	m.rules[slot] += regoRule(name, body)
+
+	return []string{name}
+}
+
+// helper reserves the next helper rule, before the helpers of its items.
+func (m *regoModule) helper() (string, int) {
+	m.rules = append(m.rules, "")
+
+	return "policy_" + strconv.Itoa(len(m.rules)), len(m.rules) - 1
+}
+
+// regoRef returns the reference to column in the object of namespace.
+func regoRef(namespace string, column string) string {
+	ref := "input." + namespace
+	for _, segment := range strings.Split(column, ".") {
+		if identifier.MatchString(segment) {
+			ref += "." + segment
+		} else {
+			ref += "[" + regoString(segment) + "]"
+		}
+	}
+
+	return ref
+}
+
+// regoMissing returns the expression that holds if column is missing from
+// the object of namespace or null.
+func regoMissing(namespace string, column string) string {
+	path := strings.Split(column, ".")
+	for i := range path {
+		path[i] = regoString(path[i])
+	}
+
+	return "object.get(input." + namespace + ", [" + strings.Join(path, ", ") + "], null) == null"
+}
+
+func regoString(value string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(value)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// regoConvert returns the expression converting a value of kind, like
+// the reference to an attribute, into a comparable value. Dates and
+// datetimes are compared as nanoseconds since the epoch.
+func regoConvert(expr string, kind string) (string, error) {
+	switch kind {
+	case KindDate:
+		return `time.parse_ns("` + dateLayout + `", ` + expr + `)`, nil
+	case KindDateTime:
+		return "time.parse_rfc3339_ns(" + expr + ")", nil
+	case KindDuration:
+		return "", fmt.Errorf("values of kind `%s` can't be parsed in Rego", kind)
+	}
+
+	return expr, nil
+}
+
+// regoValue returns the literal of value, converted like attributes of kind.
+func regoValue(value string, kind string) (string, error) {
+	if kind == KindNumber {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("assert value `%s` is not a valid %s", value, kind)
+		}
+
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+
+	return regoConvert(regoString(value), kind)
+}
+
+func regoSet(values []string, kind string) (string, error) {
+	literals := make([]string, len(values))
+	for i := range values {
+		literal, err := regoValue(values[i], kind)
+		if err != nil {
+			return "", err
+		}
+		literals[i] = literal
+	}
+
+	return "{" + strings.Join(literals, ", ") + "}", nil
+}
+
+// regoAttribute returns the reference to attr and the expression of its
+// converted value, or for multi-valued attributes the set of them.
+func regoAttribute(rule *Rule, attr RuleAttribute) (ref string, value string, err error) {
+	namespace, column, ok := namespaceOf(attr.Name)
+	if !ok {
+		return "", "", fmt.Errorf("rule `%s`: attribute `%s` has no namespace", rule.Name, attr.Name)
+	}
+	ref = regoRef(namespace, column)
+	if attr.Multi {
+		value, err = regoConvert("x", attr.Kind)
+		value = "{" + value + " | some x in " + ref + "}"
+	} else {
+		value, err = regoConvert(ref, attr.Kind)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+
+	return ref, value, nil
+}
+
+func regoTarget(rule *Rule) ([]string, error) {
+	if rule.ParsedTarget == nil {
+		return nil, fmt.Errorf("rule `%s` has no parsed target, was it validated?", rule.Name)
+	}
+	ref, x, err := regoAttribute(rule, rule.Attribute)
+	if err != nil {
+		return nil, err
+	}
+	kind := rule.Attribute.Kind
+	fail := func(err error) ([]string, error) {
+		return nil, fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+
+	switch target := rule.ParsedTarget.(type) {
+	case *TargetValue:
+		switch rule.Operator {
+		case "isSubstringOf":
+			return []string{"contains(" + regoString(target.Value) + ", " + x + ")"}, nil
+		case "startsWith":
+			return []string{"startswith(" + x + ", " + regoString(target.Value) + ")"}, nil
+		case "endsWith":
+			return []string{"endswith(" + x + ", " + regoString(target.Value) + ")"}, nil
+		}
+		value, err := regoValue(target.Value, kind)
+		if err != nil {
+			return fail(err)
+		}
+		switch rule.Operator {
+		case "equal":
+			return []string{x + " == " + value}, nil
+		case "notEqual":
+			return []string{x + " != " + value}, nil
+		case "greaterThan":
+			return []string{x + " > " + value}, nil
+		case "lessThan":
+			return []string{x + " < " + value}, nil
+		}
+	case *TargetValues:
+		values, err := regoSet(target.Values, kind)
+		if err != nil {
+			return fail(err)
+		}
+		switch {
+		case rule.Attribute.Multi && rule.Operator == "allOf":
+			return []string{values + " - " + x + " == set()"}, nil
+		case rule.Attribute.Multi:
+			return []string{x + " & " + values + " != set()"}, nil
+		}
+		switch rule.Operator {
+		case "in", "anyOf", "intersects":
+			return []string{x + " in " + values}, nil
+		case "notIn":
+			// not alone would hold for a missing attribute.
+			return []string{ref + " != null", "not " + x + " in " + values}, nil
+		case "allOf":
+			// a single value holds all values only if they are all equal.
+			body := make([]string, len(target.Values))
+			for i := range target.Values {
+				value, err := regoValue(target.Values[i], kind)
+				if err != nil {
+					return fail(err)
+				}
+				body[i] = x + " == " + value
+			}
+
+			return body, nil
+		}
+	case *TargetRange:
+		from, err := regoValue(target.From, kind)
+		if err != nil {
+			return fail(err)
+		}
+		to, err := regoValue(target.To, kind)
+		if err != nil {
+			return fail(err)
+		}
+
+		return []string{x + " >= " + from, x + " <= " + to}, nil
+	case *TargetWildcard:
+		w, err := target.Wildcard()
+		if err != nil {
+			return fail(fmt.Errorf("invalid wildcard pattern: %w", err))
+		}
+		if target.IgnoreCase {
+			x = "lower(" + x + ")"
+		}
+
+		return []string{"regex.match(" + regoString(w.regexPattern()) + ", " + x + ")"}, nil
+	case *TargetNull:
+		namespace, column, _ := namespaceOf(rule.Attribute.Name)
+
+		return []string{regoMissing(namespace, column)}, nil
+	case *TargetRelative:
+		threshold := "time.now_ns()"
+		switch offset := target.offset(rule.Operator); {
+		case offset > 0:
+			threshold += " + " + strconv.FormatInt(offset.Nanoseconds(), 10)
+		case offset < 0:
+			threshold += " - " + strconv.FormatInt(-offset.Nanoseconds(), 10)
+		}
+		if kind == KindDate {
+			// the UTC day the threshold falls on.
+			threshold = `time.parse_ns("` + dateLayout + `", time.format([` + threshold + `, "UTC", "` + dateLayout + `"]))`
+		}
+
+		return []string{x + " " + relativeOperators[rule.Operator] + " " + threshold}, nil
+	}
+
+	return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+}
+
+func regoComparison(rule *Rule) ([]string, error) {
+	if len(rule.Attributes) != 2 {
+		return nil, fmt.Errorf("rule `%s` with type `comparison` must have exactly two attributes", rule.Name)
+	}
+	if len(rule.Resolved) != 0 {
+		return nil, fmt.Errorf("rule `%s` has resolved user values, export the rule before evaluation", rule.Name)
+	}
+
+	left, right := rule.Attributes[0], rule.Attributes[1]
+	operator := rule.Operator
+	if operator == "contains" {
+		left, right, operator = right, left, "in"
+	}
+	if operator == "in" {
+		// only a multi-valued attribute holds a list, a single value must
+		// be equal.
+		operator = "equal"
+		if right.Multi {
+			operator = "in"
+		}
+	}
+
+	_, l, err := regoAttribute(rule, left)
+	if err != nil {
+		return nil, err
+	}
+	_, r, err := regoAttribute(rule, right)
+	if err != nil {
+		return nil, err
+	}
+	if operator == "in" {
+		return []string{l + " in " + r}, nil
+	}
+
+	op, ok := comparisonOperators[operator]
+	if !ok {
+		return nil, fmt.Errorf("rule `%s`: unknown operator `%s`", rule.Name, rule.Operator)
+	}
+	switch op {
+	case "=":
+		op = "=="
+	case "<>":
+		op = "!="
+	}
+
+	return []string{l + " " + op + " " + r}, nil
+}
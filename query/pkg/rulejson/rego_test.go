@@ -0,0 +1,177 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRegoExporter(t *testing.T) {
+	attribute := func(name string, kind string, operator string, assert string) *Rule {
+		rule := &Rule{
+			Name:      "rule",
+			Type:      "attribute",
+			Operator:  operator,
+			Attribute: RuleAttribute{Name: name, Kind: kind},
+		}
+		if assert != "" {
+			rule.Assert = json.RawMessage(assert)
+		}
+
+		return rule
+	}
+
+	tests := []struct {
+		name string
+		rule *Rule
+		want string
+	}{
+		{
+			name: "number",
+			rule: attribute("data.age", KindNumber, "greaterThan", `{"value": "18.0"}`),
+			want: "allow if {\n\tinput.data.age > 18\n}\n",
+		},
+		{
+			name: "quoted field",
+			rule: attribute("data.address.zip-code", "", "equal", `{"value": "2\"0"}`),
+			want: "allow if {\n\tinput.data.address[\"zip-code\"] == \"2\\\"0\"\n}\n",
+		},
+		{
+			name: "notIn requires the attribute",
+			rule: attribute("data.city", "", "notIn", `{"values": ["Hamburg", "Berlin"]}`),
+			want: "allow if {\n\tinput.data.city != null\n\tnot input.data.city in {\"Hamburg\", \"Berlin\"}\n}\n",
+		},
+		{
+			name: "date",
+			rule: attribute("data.created", KindDate, "range", `{"from": "2024-01-01", "to": "2024-12-31"}`),
+			want: "allow if {\n" +
+				"\ttime.parse_ns(\"2006-01-02\", input.data.created) >= time.parse_ns(\"2006-01-02\", \"2024-01-01\")\n" +
+				"\ttime.parse_ns(\"2006-01-02\", input.data.created) <= time.parse_ns(\"2006-01-02\", \"2024-12-31\")\n}\n",
+		},
+		{
+			name: "withinLast",
+			rule: attribute("data.created", KindDateTime, "withinLast", `{"duration": "PT1H"}`),
+			want: "allow if {\n\ttime.parse_rfc3339_ns(input.data.created) >= time.now_ns() - 3600000000000\n}\n",
+		},
+		{
+			name: "wildcard",
+			rule: attribute("data.name", "", "matchesWildcard", `{"value": "J?hn*", "ignoreCase": true}`),
+			want: "allow if {\n\tregex.match(\"^j.hn.*\\\\z\", lower(input.data.name))\n}\n",
+		},
+		{
+			name: "isNull",
+			rule: attribute("data.address.city", "", "isNull", ""),
+			want: "allow if {\n\tobject.get(input.data, [\"address\", \"city\"], null) == null\n}\n",
+		},
+		{
+			name: "multi allOf",
+			rule: &Rule{
+				Type:      "attribute",
+				Operator:  "allOf",
+				Attribute: RuleAttribute{Name: "data.tags", Multi: true},
+				Assert:    json.RawMessage(`{"values": ["red", "blue"]}`),
+			},
+			want: "allow if {\n\t{\"red\", \"blue\"} - {x | some x in input.data.tags} == set()\n}\n",
+		},
+		{
+			// under NOT a missing attribute makes the item hold, so the
+			// negation doesn't.
+			name: "not and user attribute",
+			rule: &Rule{Type: "group", Operator: "NOT", Items: []Rule{*attribute("user.role", "", "equal", `{"value": "guest"}`)}},
+			want: "allow if {\n\tnot policy_1\n}\n\npolicy_1 if {\n\tpolicy_2\n}\n\n" +
+				"policy_2 if {\n\tobject.get(input.user, [\"role\"], null) == null\n}\n\n" +
+				"policy_2 if {\n\tinput.user.role == \"guest\"\n}\n",
+		},
+		{
+			name: "user attributes in OR",
+			rule: &Rule{Type: "group", Operator: "OR", Items: []Rule{
+				*attribute("user.a", "", "equal", `{"value": "x"}`),
+				*attribute("user.b", "", "equal", `{"value": "y"}`),
+			}},
+			want: "allow if {\n\tpolicy_1\n}\n\n" +
+				"policy_1 if {\n\tinput.user.a != null\n\tinput.user.a == \"x\"\n}\n\n" +
+				"policy_1 if {\n\tinput.user.b != null\n\tinput.user.b == \"y\"\n}\n",
+		},
+		{
+			name: "comparison in multi-valued attribute",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "in",
+				Attributes: []RuleAttribute{{Name: "user.department"}, {Name: "data.departments", Multi: true}},
+			},
+			want: "allow if {\n\tinput.user.department != null\n\tinput.user.department in {x | some x in input.data.departments}\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			got, err := (&RegoExporter{Package: "policies"}).Export(tt.rule)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			want := "package policies\n\nimport rego.v1\n\ndefault allow := false\n\n" + tt.want
+			if got != want {
+				t.Errorf("Export() got = >%v<, want >%v<", got, want)
+			}
+		})
+	}
+}
+
+func TestRegoExporterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *Rule
+	}{
+		{
+			name: "duration",
+			rule: &Rule{
+				Type:      "attribute",
+				Operator:  "greaterThan",
+				Attribute: RuleAttribute{Name: "data.runtime", Kind: KindDuration},
+				Assert:    json.RawMessage(`{"value": "PT1H"}`),
+			},
+		},
+		{
+			name: "resolved comparison",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "equal",
+				Attributes: []RuleAttribute{{Name: "user.department"}, {Name: "data.department"}},
+				Resolved:   map[string][]string{"user.department": {"sales"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			if _, err := (&RegoExporter{}).Export(tt.rule); err == nil {
+				t.Errorf("Export() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestRegoExporterDeny(t *testing.T) {
+	rule, err := ParseExpression(`deny user.age:number < data.min_age:number`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := Validate(rule); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	got, err := (&RegoExporter{Package: "policies"}).Export(rule)
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	// a missing user attribute applies the deny.
+	want := "package policies\n\nimport rego.v1\n\ndefault deny := false\n\n" +
+		"deny if {\n\tpolicy_1\n}\n\n" +
+		"policy_1 if {\n\tobject.get(input.user, [\"age\"], null) == null\n}\n\n" +
+		"policy_1 if {\n\tinput.user.age < input.data.min_age\n}\n"
+	if got != want {
+		t.Errorf("Export() got = >%v<, want >%v<", got, want)
+	}
+}
@@ -0,0 +1,246 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func testClock() time.Time {
+	return testNow
+}
+
+func TestEvaluateRelative(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		assert   string
+		kind     string
+		input    string
+		want     string
+	}{
+		{
+			name:     "within last 90 days",
+			operator: "withinLast",
+			assert:   `{"duration": "P90D"}`,
+			kind:     KindDateTime,
+			input:    "2024-04-01T00:00:00Z",
+			want:     "true",
+		},
+		{
+			name:     "not within last 90 days",
+			operator: "withinLast",
+			assert:   `{"duration": "P90D"}`,
+			kind:     KindDateTime,
+			input:    "2024-03-01T00:00:00Z",
+			want:     "false",
+		},
+		{
+			name:     "date on the first day of the window",
+			operator: "withinLast",
+			assert:   `{"duration": "P90D"}`,
+			kind:     KindDate,
+			input:    "2024-03-17",
+			want:     "true",
+		},
+		{
+			name:     "older than a year",
+			operator: "olderThan",
+			assert:   `{"duration": "P1Y"}`,
+			kind:     KindDate,
+			input:    "2023-01-01",
+			want:     "true",
+		},
+		{
+			name:     "before now",
+			operator: "beforeNow",
+			kind:     KindDateTime,
+			input:    "2024-06-15T13:00:00+02:00",
+			want:     "true",
+		},
+		{
+			name:     "embargo not lifted",
+			operator: "afterNow",
+			kind:     KindDate,
+			input:    "2024-07-01",
+			want:     "true",
+		},
+		{
+			name:     "after now with offset",
+			operator: "afterNow",
+			assert:   `{"duration": "P30D"}`,
+			kind:     KindDate,
+			input:    "2024-07-01",
+			want:     "false",
+		},
+		{
+			name:     "invalid value",
+			operator: "beforeNow",
+			kind:     KindDate,
+			input:    "yesterday",
+			want:     "false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{
+				Type:      "attribute",
+				Operator:  tt.operator,
+				Attribute: RuleAttribute{Name: "data.created", Kind: tt.kind},
+			}
+			if tt.assert != "" {
+				rule.Assert = json.RawMessage(tt.assert)
+			}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			evaluated, err := rule.Evaluate(map[string]string{"data.created": tt.input}, WithClock(testClock))
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			if evaluated.Decision.String() != tt.want {
+				t.Errorf("Evaluate() got = >%v<, want >%v<", evaluated.Decision.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLRelative(t *testing.T) {
+	rule := &Rule{
+		Type:     "group",
+		Operator: "AND",
+		Items: []Rule{
+			{
+				Type:      "attribute",
+				Operator:  "withinLast",
+				Attribute: RuleAttribute{Name: "data.created", Kind: KindDateTime},
+				Assert:    json.RawMessage(`{"duration": "P90D"}`),
+			},
+			{
+				Type:      "attribute",
+				Operator:  "beforeNow",
+				Attribute: RuleAttribute{Name: "data.published", Kind: KindDate},
+			},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		builder   *SQLBuilder
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:    "postgres",
+			builder: &SQLBuilder{Dialect: Postgres},
+			wantWhere: `( "created" >= (now() - CAST($1 AS INTERVAL)) AND ` +
+				`"published" < CAST(now() AT TIME ZONE 'UTC' AS DATE) )`,
+			wantArgs: []any{"P90D"},
+		},
+		{
+			name:    "mysql",
+			builder: &SQLBuilder{Dialect: MySQL},
+			wantWhere: "( `created` >= (UTC_TIMESTAMP(6) - INTERVAL ? SECOND) AND " +
+				"`published` < DATE(UTC_TIMESTAMP(6)) )",
+			wantArgs: []any{"7776000"},
+		},
+		{
+			name:    "sqlite",
+			builder: &SQLBuilder{Dialect: SQLite},
+			wantWhere: `( "created" >= datetime('now', ?) AND ` +
+				`"published" < date('now') )`,
+			wantArgs: []any{"-7776000 seconds"},
+		},
+		{
+			name:    "sqlserver",
+			builder: &SQLBuilder{Dialect: SQLServer},
+			wantWhere: `( [created] >= DATEADD(second, @p1, SYSDATETIMEOFFSET()) AND ` +
+				`[published] < CAST(SWITCHOFFSET(SYSDATETIMEOFFSET(), '+00:00') AS DATE) )`,
+			wantArgs: []any{"-7776000"},
+		},
+		{
+			name:    "postgres with clock",
+			builder: &SQLBuilder{Dialect: Postgres, Clock: testClock},
+			wantWhere: `( "created" >= CAST($1 AS TIMESTAMPTZ) AND ` +
+				`"published" < CAST($2 AS DATE) )`,
+			wantArgs: []any{"2024-03-17T12:00:00Z", "2024-06-15"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, err := tt.builder.Compile(rule)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(tt.builder.Args(), tt.wantArgs) {
+				t.Errorf("Args() got = %v, want %v", tt.builder.Args(), tt.wantArgs)
+			}
+		})
+	}
+
+	evaluated, err := rule.Evaluate(map[string]string{})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+	wantString := `( data.created >= (now() - INTERVAL 'P90D') AND ` +
+		`data.published < CAST(now() AT TIME ZONE 'UTC' AS DATE) )`
+	if evaluated.Stringer() != wantString {
+		t.Errorf("Stringer() got = >%v<, want >%v<", evaluated.Stringer(), wantString)
+	}
+}
+
+func TestValidateRelative(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		kind     string
+		assert   string
+		want     []RuleError
+	}{
+		{
+			name:     "missing duration",
+			operator: "withinLast",
+			kind:     KindDate,
+			assert:   `{}`,
+			want:     []RuleError{{Name: "created", Code: CodeAssertMissing, Err: "rule with operator `withinLast` must have field `duration` set"}},
+		},
+		{
+			name:     "invalid duration",
+			operator: "olderThan",
+			kind:     KindDateTime,
+			assert:   `{"duration": "90 days"}`,
+			want:     []RuleError{{Name: "created", Code: CodeAssertValueInvalid, Err: "assert value `90 days` is not a valid duration"}},
+		},
+		{
+			name:     "wrong kind",
+			operator: "afterNow",
+			kind:     KindNumber,
+			want:     []RuleError{{Name: "created", Code: CodeOperatorKindInvalid, Err: "rule with operator `afterNow` must have an attribute of kind `date` or `datetime`"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{
+				Name:      "created",
+				Type:      "attribute",
+				Operator:  tt.operator,
+				Attribute: RuleAttribute{Name: "data.created", Kind: tt.kind},
+			}
+			if tt.assert != "" {
+				rule.Assert = json.RawMessage(tt.assert)
+			}
+			if got := Validate(rule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// EvaluateRow decides rule for a single record, like a database would
+// for the WHERE fragment SQLBuilder compiles from the result of
+// EvaluateInput. Data attributes are looked up in row by their column,
+// data.city by the key city. Missing keys and nil values are NULL and
+// follow SQL's three-valued logic: a comparison with NULL is unknown,
+// NOT holds for an unknown item and the record is only allowed if the
+// rule is true.
+//
+// Values of multi-valued attributes are slices, other values are
+// strings, numbers, bools, time.Time or time.Duration.
+func (rule *Rule) EvaluateRow(input Input, row map[string]any, opts ...EvaluateOption) (bool, error) {
+	ev := newEvaluation(input, rule.Effect, opts)
+
+	cop := &Rule{}
+	cloneRule(rule, cop)
+	if err := evaluateRule(cop, ev); err != nil {
+		return false, err
+	}
+
+	result, err := evaluateRowRule(cop, row, ev.now)
+	if err != nil {
+		return false, err
+	}
+
+	return result == truthTrue, nil
+}
+
+// truth is a value of SQL's three-valued logic.
+type truth int8
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+func truthFor(value bool) truth {
+	if value {
+		return truthTrue
+	}
+
+	return truthFalse
+}
+
+func evaluateRowRule(rule *Rule, row map[string]any, now time.Time) (truth, error) {
+	if rule.Decision.Decided() {
+		return truthFor(rule.Decision == True), nil
+	}
+
+	switch rule.Type {
+	case "bool":
+		return truthFor(rule.Operator == "true"), nil
+	case "attribute":
+		return evaluateRowAttribute(rule, row, now)
+	case "comparison":
+		return evaluateRowComparison(rule, row)
+	case "group":
+		if rule.Operator == "NOT" {
+			if len(rule.Items) != 1 {
+				return truthFalse, fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+			}
+			item, err := evaluateRowRule(&rule.Items[0], row, now)
+			if err != nil {
+				return truthFalse, err
+			}
+
+			// NOT compiles to IS NOT TRUE, unknown items are negated to true.
+			return truthFor(item != truthTrue), nil
+		}
+
+		// the value that decides the group, false for AND and true for OR.
+		decisive := truthFor(rule.Operator == "OR")
+		result := truthFor(rule.Operator == "AND")
+		for i := range rule.Items {
+			item, err := evaluateRowRule(&rule.Items[i], row, now)
+			if err != nil {
+				return truthFalse, err
+			}
+			if item == decisive {
+				return decisive, nil
+			}
+			if item == truthUnknown {
+				result = truthUnknown
+			}
+		}
+
+		return result, nil
+	}
+
+	return truthFalse, fmt.Errorf("allowed rule types are `attribute`, `bool`, `comparison` or `group` got: `%s`", rule.Type)
+}
+
+func evaluateRowAttribute(rule *Rule, row map[string]any, now time.Time) (truth, error) {
+	if rule.ParsedTarget == nil {
+		return truthFalse, fmt.Errorf("rule `%s` has no parsed target, was it validated?", rule.Name)
+	}
+	values, null, err := rowValues(rule, rule.Attribute, row)
+	if err != nil {
+		return truthFalse, err
+	}
+	if rule.Operator == "isNull" {
+		return truthFor(null), nil
+	}
+	if null {
+		return truthUnknown, nil
+	}
+
+	return truthFor(evaluateValues(rule.Operator, rule.ParsedTarget, values, rule.Attribute.Kind, now)), nil
+}
+
+func evaluateRowComparison(rule *Rule, row map[string]any) (truth, error) {
+	if len(rule.Attributes) != 2 {
+		return truthFalse, fmt.Errorf("rule `%s` with type `comparison` must have exactly two attributes", rule.Name)
+	}
+
+	sides := make([][]string, 2)
+	for i, attr := range rule.Attributes {
+		if values, ok := rule.Resolved[attr.Name]; ok {
+			sides[i] = values
+
+			continue
+		}
+		values, null, err := rowValues(rule, attr, row)
+		if err != nil {
+			return truthFalse, err
+		}
+		if null {
+			return truthUnknown, nil
+		}
+		sides[i] = values
+	}
+
+	return truthFor(evaluateComparison(rule.Operator, sides[0], sides[1], kindOf(rule.Attributes[0]))), nil
+}
+
+// rowValues returns the values of the column of attr in row, null is set
+// for missing keys and nil values.
+func rowValues(rule *Rule, attr RuleAttribute, row map[string]any) (values []string, null bool, err error) {
+	column, err := dataColumn(attr.Name)
+	if err != nil {
+		return nil, false, fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+	value, ok := row[column]
+	if !ok || value == nil {
+		return nil, true, nil
+	}
+
+	var items []any
+	switch v := value.(type) {
+	case []any:
+		items = v
+	case []string:
+		items = make([]any, len(v))
+		for i := range v {
+			items[i] = v[i]
+		}
+	default:
+		if attr.Multi {
+			return nil, false, fmt.Errorf("rule `%s`: column `%s` of multi-valued attribute must be a slice, got %T", rule.Name, column, value)
+		}
+		items = []any{value}
+	}
+	if !attr.Multi && len(items) != 1 {
+		return nil, false, fmt.Errorf("rule `%s`: column `%s` must hold a single value, got %d", rule.Name, column, len(items))
+	}
+
+	values = make([]string, 0, len(items))
+	for _, item := range items {
+		// NULL elements of an array never match.
+		if item == nil {
+			continue
+		}
+		value, err := formatRowValue(item, attr.Kind)
+		if err != nil {
+			return nil, false, fmt.Errorf("rule `%s`: column `%s`: %w", rule.Name, column, err)
+		}
+		values = append(values, value)
+	}
+
+	return values, false, nil
+}
+
+// formatRowValue formats a value of a row like the values of Input.
+func formatRowValue(value any, kind string) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case time.Time:
+		return formatTime(v, kind), nil
+	case time.Duration:
+		return formatDuration(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	return "", fmt.Errorf("unsupported value type %T", value)
+}
@@ -0,0 +1,281 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEvaluateRow(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	attribute := func(name string, kind string, operator string, assert string) *Rule {
+		rule := &Rule{
+			Name:      "rule",
+			Type:      "attribute",
+			Operator:  operator,
+			Attribute: RuleAttribute{Name: name, Kind: kind},
+		}
+		if assert != "" {
+			rule.Assert = json.RawMessage(assert)
+		}
+
+		return rule
+	}
+	not := func(item *Rule) *Rule {
+		return &Rule{Name: "not", Type: "group", Operator: "NOT", Items: []Rule{*item}}
+	}
+
+	tests := []struct {
+		name  string
+		rule  *Rule
+		input Input
+		row   map[string]any
+		want  bool
+	}{
+		{
+			name: "equal",
+			rule: attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+			row:  map[string]any{"city": "Hamburg"},
+			want: true,
+		},
+		{
+			name: "equal null",
+			rule: attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+			row:  map[string]any{"city": nil},
+			want: false,
+		},
+		{
+			name: "notEqual null",
+			rule: attribute("data.city", "", "notEqual", `{"value": "Hamburg"}`),
+			row:  map[string]any{},
+			want: false,
+		},
+		{
+			name: "not of null is true",
+			rule: not(attribute("data.city", "", "equal", `{"value": "Hamburg"}`)),
+			row:  map[string]any{},
+			want: true,
+		},
+		{
+			name: "isNull",
+			rule: attribute("data.city", "", "isNull", ""),
+			row:  map[string]any{"city": nil},
+			want: true,
+		},
+		{
+			name: "number",
+			rule: attribute("data.age", KindNumber, "greaterThan", `{"value": "9"}`),
+			row:  map[string]any{"age": 10},
+			want: true,
+		},
+		{
+			name: "number as json",
+			rule: attribute("data.age", KindNumber, "in", `{"values": ["10", "11"]}`),
+			row:  map[string]any{"age": json.Number("10.0")},
+			want: true,
+		},
+		{
+			name: "date",
+			rule: attribute("data.created", KindDate, "withinLast", `{"duration": "P7D"}`),
+			row:  map[string]any{"created": time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)},
+			want: true,
+		},
+		{
+			name: "date older",
+			rule: attribute("data.created", KindDate, "withinLast", `{"duration": "P7D"}`),
+			row:  map[string]any{"created": "2024-06-01"},
+			want: false,
+		},
+		{
+			name: "multi anyOf",
+			rule: &Rule{
+				Type:      "attribute",
+				Operator:  "anyOf",
+				Attribute: RuleAttribute{Name: "data.tags", Multi: true},
+				Assert:    json.RawMessage(`{"values": ["red", "blue"]}`),
+			},
+			row:  map[string]any{"tags": []any{"green", "blue"}},
+			want: true,
+		},
+		{
+			name: "multi empty is not null",
+			rule: &Rule{
+				Type:      "attribute",
+				Operator:  "isNull",
+				Attribute: RuleAttribute{Name: "data.tags", Multi: true},
+			},
+			row:  map[string]any{"tags": []string{}},
+			want: false,
+		},
+		{
+			name: "comparison with user",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "equal",
+				Attributes: []RuleAttribute{{Name: "data.department"}, {Name: "user.department"}},
+			},
+			input: Input{"user.department": {"sales"}},
+			row:   map[string]any{"department": "sales"},
+			want:  true,
+		},
+		{
+			name: "comparison of columns",
+			rule: &Rule{
+				Type:       "comparison",
+				Operator:   "lt",
+				Attributes: []RuleAttribute{{Name: "data.start", Kind: KindNumber}, {Name: "data.end", Kind: KindNumber}},
+			},
+			row:  map[string]any{"start": 2, "end": 10.5},
+			want: true,
+		},
+		{
+			name: "comparison with null",
+			rule: not(&Rule{
+				Type:       "comparison",
+				Operator:   "lt",
+				Attributes: []RuleAttribute{{Name: "data.start", Kind: KindNumber}, {Name: "data.end", Kind: KindNumber}},
+			}),
+			row:  map[string]any{"start": 2},
+			want: true,
+		},
+		{
+			name: "unknown in OR",
+			rule: &Rule{Type: "group", Operator: "OR", Items: []Rule{
+				*attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+				*attribute("data.country", "", "equal", `{"value": "de"}`),
+			}},
+			row:  map[string]any{"country": "de"},
+			want: true,
+		},
+		{
+			name: "unknown in AND",
+			rule: not(&Rule{Type: "group", Operator: "AND", Items: []Rule{
+				*attribute("data.city", "", "equal", `{"value": "Hamburg"}`),
+				*attribute("data.country", "", "equal", `{"value": "de"}`),
+			}}),
+			row:  map[string]any{"country": "de"},
+			want: true,
+		},
+		{
+			name:  "user decides",
+			rule:  attribute("user.role", "", "equal", `{"value": "admin"}`),
+			input: Input{"user.role": {"admin"}},
+			row:   map[string]any{},
+			want:  true,
+		},
+		{
+			name: "missing user attribute denies",
+			rule: not(attribute("user.role", "", "equal", `{"value": "admin"}`)),
+			row:  map[string]any{},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			got, err := tt.rule.EvaluateRow(tt.input, tt.row, WithClock(func() time.Time { return now }))
+			if err != nil {
+				t.Fatalf("EvaluateRow() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateRow() got = >%v<, want >%v<", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateRowErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		attr RuleAttribute
+		row  map[string]any
+	}{
+		{name: "multi needs a slice", attr: RuleAttribute{Name: "data.tags", Multi: true}, row: map[string]any{"tags": "red"}},
+		{name: "single needs a value", attr: RuleAttribute{Name: "data.tags"}, row: map[string]any{"tags": []string{"red", "blue"}}},
+		{name: "unsupported type", attr: RuleAttribute{Name: "data.tags"}, row: map[string]any{"tags": struct{}{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{
+				Type:      "attribute",
+				Operator:  "anyOf",
+				Attribute: tt.attr,
+				Assert:    json.RawMessage(`{"values": ["red"]}`),
+			}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			if _, err := rule.EvaluateRow(nil, tt.row); err == nil {
+				t.Errorf("EvaluateRow() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestEvaluateRowSubstringEmpty(t *testing.T) {
+	// an empty column is a substring of every value, in memory and in the
+	// SQL of every dialect.
+	tests := []struct {
+		value  string
+		column any
+		want   bool
+	}{
+		{value: "", column: "", want: true},
+		{value: "", column: "a", want: false},
+		{value: "abc", column: "", want: true},
+		{value: "abc", column: "b", want: true},
+		{value: "abc", column: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+"/"+fmt.Sprint(tt.column), func(t *testing.T) {
+			rule := &Rule{
+				Type:      "attribute",
+				Operator:  "isSubstringOf",
+				Attribute: RuleAttribute{Name: "data.code"},
+				Assert:    json.RawMessage(`{"value": "` + tt.value + `"}`),
+			}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			got, err := rule.EvaluateRow(nil, map[string]any{"code": tt.column})
+			if err != nil {
+				t.Fatalf("EvaluateRow() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EvaluateRow() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	rule := &Rule{
+		Type:      "attribute",
+		Operator:  "isSubstringOf",
+		Attribute: RuleAttribute{Name: "data.code"},
+		Assert:    json.RawMessage(`{"value": ""}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	dialects := []struct {
+		dialect   Dialect
+		wantWhere string
+	}{
+		{Postgres, `position("code" IN $1) > 0`},
+		{MySQL, "LOCATE(`code` COLLATE utf8mb4_bin, ?) > 0"},
+		{SQLite, `instr(?, "code") > 0`},
+		{SQLServer, `(CHARINDEX([code] COLLATE Latin1_General_BIN2, @p1) > 0 OR DATALENGTH([code] COLLATE Latin1_General_BIN2) = 0)`},
+	}
+	for _, tt := range dialects {
+		b := &SQLBuilder{Dialect: tt.dialect}
+		where, err := b.Compile(rule)
+		if err != nil {
+			t.Fatalf("Compile() error = %v", err)
+		}
+		if where != tt.wantWhere {
+			t.Errorf("%s: Compile() got = >%v<, want >%v<", tt.dialect.Name(), where, tt.wantWhere)
+		}
+	}
+}
@@ -6,270 +6,610 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type RuleAttribute struct {
 	Name string `json:"name"`
+	// one of the Kind constants, defaults to string.
 	Kind string `json:"kind"`
+	// the data column holds a list of values of Kind, an array in
+	// Postgres and a JSON array in the other dialects.
+	Multi bool `json:"multi"`
 }
 
 type Rule struct {
 	Name string `json:"name"`
-	// possible values "attribute" or "group".
+	// possible values "attribute", "bool", "comparison" or "group".
 	Type string `json:"type"`
-	// with type=group, possible values "AND" or "OR" only,
-	// with type=attribute this field is the target type.
+	// with type=group, possible values "AND", "OR" or "NOT" with a single item,
+	// with type=attribute this field is the target type,
+	// with type=comparison one of "equal", "notEqual", "lt", "lte", "gt",
+	// "gte", "in" or "contains".
 	Operator string `json:"operator"`
 	// only relevant with type=group
 	Items []Rule `json:"items"`
 	// only relevant with type=attribute
 	Attribute RuleAttribute `json:"attribute"`
-	// only relevant with type=attribute
+	// only relevant with type=comparison, the two attributes compared
 	Attributes []RuleAttribute `json:"attributes"`
+	// only relevant on the root of a policy, "permit" or "deny", see
+	// Combine. Rules without an effect permit.
+	Effect string `json:"effect,omitempty"`
 
-	Assert       json.RawMessage `json:"assert"`
+	Assert       json.RawMessage `json:"assert,omitempty"`
 	ParsedTarget any             `json:"-"`
-	BoolValue    string          `json:"-"`
+	// set by evaluation, decided rules are replaced by bool rules so a
+	// residual serializes back to rule JSON.
+	Decision Decision `json:"decision,omitempty"`
+	// user values substituted into a comparison during evaluation,
+	// keyed by attribute name.
+	Resolved map[string][]string `json:"resolved,omitempty"`
+}
+
+// Decision is the result of evaluating a rule, the zero value marks rules
+// that weren't evaluated.
+type Decision int8
+
+const (
+	Unevaluated Decision = iota
+	True
+	False
+	// Residual rules depend on data attributes and are left for the
+	// compile target, evaluating them again doesn't change them.
+	Residual
+)
+
+var decisionNames = map[Decision]string{
+	Unevaluated: "unevaluated",
+	True:        "true",
+	False:       "false",
+	Residual:    "residual",
+}
+
+func decisionFor(value bool) Decision {
+	if value {
+		return True
+	}
+
+	return False
+}
+
+// Decided reports whether d is True or False.
+func (d Decision) Decided() bool {
+	return d == True || d == False
+}
+
+func (d Decision) String() string {
+	if name, ok := decisionNames[d]; ok {
+		return name
+	}
+
+	return "Decision(" + strconv.Itoa(int(d)) + ")"
+}
+
+func (d Decision) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
 }
 
+func (d *Decision) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("decision must be a string: %w", err)
+	}
+	for decision, decisionName := range decisionNames {
+		if name == decisionName {
+			*d = decision
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown decision `%s`", name)
+}
+
+// RuleError is a problem Validate found in a rule of a policy.
 type RuleError struct {
-	Name string `json:"name"`
-	Err  string `json:"error"`
+	// Path is the JSON Pointer to the rule in the policy, like /items/0
+	// for its first item. It is empty for the policy itself.
+	Path string    `json:"path"`
+	Name string    `json:"name"`
+	Code ErrorCode `json:"code"`
+	Err  string    `json:"error"`
 }
 
+// ErrorCode identifies the kind of a RuleError, codes are stable while
+// the messages may change.
+type ErrorCode string
+
+const (
+	CodeRuleTypeMissing           ErrorCode = "RULE_TYPE_MISSING"
+	CodeRuleTypeInvalid           ErrorCode = "RULE_TYPE_INVALID"
+	CodeBoolOperatorInvalid       ErrorCode = "RULE_BOOL_OPERATOR_INVALID"
+	CodeGroupEmpty                ErrorCode = "RULE_GROUP_EMPTY"
+	CodeGroupOperatorInvalid      ErrorCode = "RULE_GROUP_OPERATOR_INVALID"
+	CodeGroupNotItemCount         ErrorCode = "RULE_GROUP_NOT_ITEM_COUNT"
+	CodeItemsNotAllowed           ErrorCode = "RULE_ITEMS_NOT_ALLOWED"
+	CodeEffectInvalid             ErrorCode = "RULE_EFFECT_INVALID"
+	CodeEffectNotAllowed          ErrorCode = "RULE_EFFECT_NOT_ALLOWED"
+	CodeAttributeMissing          ErrorCode = "ATTRIBUTE_MISSING"
+	CodeAttributeNotAllowed       ErrorCode = "ATTRIBUTE_NOT_ALLOWED"
+	CodeAttributeNamespace        ErrorCode = "ATTRIBUTE_NAMESPACE_INVALID"
+	CodeAttributeOperatorUnknown  ErrorCode = "ATTRIBUTE_OPERATOR_UNKNOWN"
+	CodeMultiOperatorInvalid      ErrorCode = "ATTRIBUTE_MULTI_OPERATOR_INVALID"
+	CodeComparisonOperatorUnknown ErrorCode = "COMPARISON_OPERATOR_UNKNOWN"
+	CodeComparisonAttributeCount  ErrorCode = "COMPARISON_ATTRIBUTE_COUNT"
+	CodeComparisonKindMismatch    ErrorCode = "COMPARISON_KIND_MISMATCH"
+	CodeAssertMissing             ErrorCode = "ASSERT_MISSING"
+	CodeAssertNotAllowed          ErrorCode = "ASSERT_NOT_ALLOWED"
+	CodeAssertDecodeFailed        ErrorCode = "ASSERT_DECODE_FAILED"
+	CodeAssertValuesEmpty         ErrorCode = "ASSERT_VALUES_EMPTY"
+	CodeAssertValueInvalid        ErrorCode = "ASSERT_VALUE_INVALID"
+	CodeWildcardInvalid           ErrorCode = "ASSERT_WILDCARD_INVALID"
+	CodeAssertFieldUnknown        ErrorCode = "ASSERT_FIELD_UNKNOWN"
+	CodeKindUnknown               ErrorCode = "KIND_UNKNOWN"
+	CodeOperatorKindInvalid       ErrorCode = "OPERATOR_KIND_INVALID"
+	CodeTableRequired             ErrorCode = "CATALOG_TABLE_REQUIRED"
+	CodeTableUnknown              ErrorCode = "CATALOG_TABLE_UNKNOWN"
+	CodeAttributeUnknown          ErrorCode = "ATTRIBUTE_UNKNOWN"
+	CodeAttributeKindMismatch     ErrorCode = "ATTRIBUTE_KIND_MISMATCH"
+)
+
 func Validate(rule *Rule) []RuleError {
 	var errs []RuleError
-	validate(rule, &errs)
+	validate(rule, "", &errs)
 	return errs
 }
 
-func validate(rule *Rule, errs *[]RuleError) {
+func validate(rule *Rule, path string, errs *[]RuleError) {
+	start := len(*errs)
 	if rule.Name == "" {
 		rule.Name = "MissingName"
 	}
 	if rule.Type == "" {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeRuleTypeMissing,
 			Err:  "empty or missing type",
 		})
 	}
 	if !slices.Contains([]string{"group", "attribute", "bool", "comparison", ""}, rule.Type) {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeRuleTypeInvalid,
 			Err:  "invalid rule type, must be `group`, `bool` or `attribute`",
 		})
 	}
+	if rule.Type == "bool" && !slices.Contains([]string{"true", "false"}, rule.Operator) {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeBoolOperatorInvalid,
+			Err:  "rule with type `bool` must have `true` or `false` operator",
+		})
+	}
 	if rule.Type == "group" && len(rule.Items) == 0 {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeGroupEmpty,
 			Err:  "rule with type `group` rule must have at least one item",
 		})
 	}
 	if rule.Type == "attribute" && len(rule.Items) > 0 {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeItemsNotAllowed,
 			Err:  "rule with type `attribute` must have no child item",
 		})
 	}
-	if rule.Type == "group" && !slices.Contains([]string{"AND", "OR"}, rule.Operator) {
+	if rule.Type == "group" && !slices.Contains([]string{"AND", "OR", "NOT"}, rule.Operator) {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
-			Err:  "rule with type `group` must have `AND` or `OR` operator",
+			Code: CodeGroupOperatorInvalid,
+			Err:  "rule with type `group` must have `AND`, `OR` or `NOT` operator",
+		})
+	}
+	if rule.Type == "group" && rule.Operator == "NOT" && len(rule.Items) > 1 {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeGroupNotItemCount,
+			Err:  "rule with type `group` and operator `NOT` must have exactly one item",
 		})
 	}
 	if rule.Attribute.Name == "" && rule.Type == "attribute" {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeAttributeMissing,
 			Err:  "rule with type `attribute` must have field `attribute` set",
 		})
 	}
+	if rule.Type == "attribute" {
+		validateNamespace(rule, rule.Attribute.Name, errs)
+	}
 	if rule.Attribute.Name != "" && rule.Type == "group" {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeAttributeNotAllowed,
 			Err:  "rule with type `group` shouldn't have field `attribute`",
 		})
 	}
-	if rule.Assert == nil && rule.Type == "attribute" {
+	if rule.Assert == nil && rule.Type == "attribute" && !slices.Contains([]string{"isNull", "beforeNow", "afterNow"}, rule.Operator) {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeAssertMissing,
 			Err:  "rule with type `attribute` must have field `target` set",
 		})
 	}
 	if rule.Assert != nil && rule.Type == "group" {
 		*errs = append(*errs, RuleError{
 			Name: rule.Name,
+			Code: CodeAssertNotAllowed,
 			Err:  "rule with type `group` shouldn't have field `target` set",
 		})
 	}
+	validateEffect(rule, path == "", errs)
+	if rule.Type == "attribute" || rule.Type == "comparison" {
+		typeCheck(rule, errs)
+	}
+	if rule.Type == "comparison" {
+		validateComparison(rule, errs)
+		for _, attr := range rule.Attributes {
+			validateNamespace(rule, attr.Name, errs)
+		}
+	}
 	if rule.Type == "attribute" {
-		var err error
-		switch rule.Operator {
-		case "range":
-			val := &TargetRange{}
-			err = json.Unmarshal(rule.Assert, val)
-			rule.ParsedTarget = val
-		case "equal", "isSubstringOf", "matchesWildcard":
-			val := &TargetValue{}
-			err = json.Unmarshal(rule.Assert, val)
-			rule.ParsedTarget = val
+		target, err := decodeTarget(rule.Operator, rule.Assert)
+		rule.ParsedTarget = target
+		switch val := target.(type) {
+		case nil:
+			*errs = append(*errs, RuleError{
+				Name: rule.Name,
+				Code: CodeAttributeOperatorUnknown,
+				Err:  "unknown operator `" + rule.Operator + "` for rule with type `attribute`",
+			})
+		case *TargetValues:
+			if err == nil && len(val.Values) == 0 {
+				*errs = append(*errs, RuleError{
+					Name: rule.Name,
+					Code: CodeAssertValuesEmpty,
+					Err:  "rule with operator `" + rule.Operator + "` must have at least one value",
+				})
+			}
+		case *TargetRelative:
+			if err == nil {
+				validateRelative(rule, val, errs)
+			}
+		case *TargetWildcard:
+			if err == nil {
+				if _, wErr := val.Wildcard(); wErr != nil {
+					*errs = append(*errs, RuleError{
+						Name: rule.Name,
+						Code: CodeWildcardInvalid,
+						Err:  "invalid wildcard pattern: " + wErr.Error(),
+					})
+				}
+			}
 		}
 		if err != nil {
 			*errs = append(*errs, RuleError{
 				Name: rule.Name,
+				Code: CodeAssertDecodeFailed,
 				Err:  "could not decode rule target",
 			})
 		}
+		if rule.Attribute.Multi && !slices.Contains([]string{"anyOf", "allOf", "intersects", "isNull"}, rule.Operator) {
+			*errs = append(*errs, RuleError{
+				Name: rule.Name,
+				Code: CodeMultiOperatorInvalid,
+				Err:  "rule with multi-valued attribute must have operator `anyOf`, `allOf`, `intersects` or `isNull`",
+			})
+		}
+		for _, value := range assertValues(rule.Operator, rule.ParsedTarget) {
+			if _, pErr := parseValue(rule.Attribute.Kind, value); pErr != nil {
+				*errs = append(*errs, RuleError{
+					Name: rule.Name,
+					Code: CodeAssertValueInvalid,
+					Err:  fmt.Sprintf("assert value `%s` is not a valid %s", value, rule.Attribute.Kind),
+				})
+			}
+		}
+	}
+	// the checks above only report errors of rule itself.
+	for i := start; i < len(*errs); i++ {
+		(*errs)[i].Path = path
 	}
 	if len(rule.Items) > 0 {
 		for i := range rule.Items {
-			validate(&rule.Items[i], errs)
+			validate(&rule.Items[i], path+"/items/"+strconv.Itoa(i), errs)
 		}
 	}
 }
 
-func (rule *Rule) Stringer() string {
-	if rule.Type == "bool" && !slices.Contains([]string{"true", "false"}, rule.Operator) {
-		return "N/A"
+// decodeTarget decodes the assert of an attribute rule with operator, the
+// target is nil for unknown operators. Operators without a value may omit
+// the assert.
+func decodeTarget(operator string, assert json.RawMessage) (any, error) {
+	var target any
+	switch operator {
+	case "range":
+		target = &TargetRange{}
+	case "equal", "notEqual", "greaterThan", "lessThan", "isSubstringOf", "startsWith", "endsWith":
+		target = &TargetValue{}
+	case "in", "notIn", "anyOf", "allOf", "intersects":
+		target = &TargetValues{}
+	case "isNull":
+		return &TargetNull{}, nil
+	case "withinLast", "olderThan", "beforeNow", "afterNow":
+		target = &TargetRelative{}
+		if assert == nil {
+			return target, nil
+		}
+	case "matchesWildcard":
+		target = &TargetWildcard{}
+	default:
+		return nil, nil
 	}
-	if rule.Type == "bool" {
+
+	return target, json.Unmarshal(assert, target)
+}
+
+func validateRelative(rule *Rule, target *TargetRelative, errs *[]RuleError) {
+	if target.Duration == "" && (rule.Operator == "withinLast" || rule.Operator == "olderThan") {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeAssertMissing,
+			Err:  "rule with operator `" + rule.Operator + "` must have field `duration` set",
+		})
+	}
+	if _, err := ParseDuration(target.Duration); target.Duration != "" && err != nil {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeAssertValueInvalid,
+			Err:  fmt.Sprintf("assert value `%s` is not a valid duration", target.Duration),
+		})
+	}
+}
+
+// Stringer renders rule for humans, with values inlined and attribute
+// names as written. The output is not meant to be run, SQLBuilder compiles
+// rules to SQL. Parts that can't be rendered are shown in angle brackets.
+func (rule *Rule) Stringer() string {
+	switch {
+	case rule.Decision.Decided():
+		return rule.Decision.String()
+	case rule.Type == "bool" && slices.Contains([]string{"true", "false"}, rule.Operator):
 		return rule.Operator
+	case rule.Type == "bool":
+		return "<rule `" + rule.Name + "` has invalid bool value `" + rule.Operator + "`>"
+	case rule.Type == "group" && rule.Operator == "NOT" && len(rule.Items) == 1:
+		return "( NOT " + rule.Items[0].Stringer() + " )"
+	case rule.Type == "group":
+		values := make([]string, len(rule.Items))
+		for i := range rule.Items {
+			values[i] = rule.Items[i].Stringer()
+		}
+
+		return "( " + strings.Join(values, " "+rule.Operator+" ") + " )"
 	}
-	if rule.Type == "attribute" && rule.BoolValue == "" {
-		return "N/A"
+
+	where, err := inlineSQL(rule)
+	if err != nil {
+		return "<" + err.Error() + ">"
 	}
-	if rule.Type == "attribute" {
-		return rule.BoolValue
+
+	return where
+}
+
+// Input holds the attribute values a rule is evaluated with. An attribute
+// may carry several values, like the groups a user belongs to.
+type Input map[string][]string
+
+// Values is a single value or a list of values, it unmarshals from a JSON
+// string or array of strings.
+type Values []string
+
+func (v *Values) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*v = Values{value}
+
+		return nil
 	}
-	if rule.Type == "comparison" {
-		return rule.BoolValue
+
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("value must be a string or a list of strings: %w", err)
 	}
-	if rule.Type == "group" && rule.BoolValue != "" {
-		return "( " + rule.BoolValue + " )"
+	*v = values
+
+	return nil
+}
+
+// evaluation holds the state of a single Evaluate call.
+type evaluation struct {
+	input   Input
+	now     time.Time
+	missing MissingPolicy
+	// missingValue is the result of a rule deciding on an attribute missing
+	// with MissingDeny, it is flipped inside NOT.
+	missingValue bool
+	// resolved holds the Resolved maps of CompiledPolicy residuals by
+	// attribute name.
+	resolved map[string]map[string][]string
+}
+
+// EvaluateOption configures Evaluate.
+type EvaluateOption func(*evaluation)
+
+// WithClock sets the clock relative time operators are evaluated against,
+// it is read once per evaluation and defaults to time.Now.
+func WithClock(clock func() time.Time) EvaluateOption {
+	return func(ev *evaluation) {
+		ev.now = clock()
 	}
-	if rule.Type == "group" {
-		values := []string{}
-		for _, child := range rule.Items {
-			values = append(values, child.Stringer())
-		}
+}
 
-		return "( " + strings.Join(values, " "+rule.Operator+" ") + " )"
+func newEvaluation(input Input, effect string, opts []EvaluateOption) *evaluation {
+	ev := &evaluation{
+		input:        input,
+		missingValue: effect == EffectDeny,
+	}
+	for _, opt := range opts {
+		opt(ev)
+	}
+	if ev.now.IsZero() {
+		ev.now = time.Now()
+	}
+
+	return ev
+}
+
+// Evaluate evaluates rule with single valued attributes, see EvaluateInput.
+func (rule *Rule) Evaluate(input map[string]string, opts ...EvaluateOption) (*Rule, error) {
+	multi := make(Input, len(input))
+	for name, value := range input {
+		multi[name] = []string{value}
 	}
 
-	return "INVALID RULE"
+	return rule.EvaluateInput(multi, opts...)
 }
 
-func (rule *Rule) Evaluate(input map[string]string) (*Rule, error) {
+// EvaluateInput decides the parts of rule that only depend on input and
+// returns a copy in which they are replaced by bool rules holding their
+// Decision. If the root is decided the result is a bool rule, otherwise
+// it is the residual left for the compile target.
+func (rule *Rule) EvaluateInput(input Input, opts ...EvaluateOption) (*Rule, error) {
+	ev := newEvaluation(input, rule.Effect, opts)
+
 	cop := &Rule{}
 	cloneRule(rule, cop)
 
-	err := evaluateRule(cop, input)
+	err := evaluateRule(cop, ev)
 	if err != nil {
 		return nil, err
 	}
+	// a decided root is replaced, the result keeps the effect.
+	cop.Effect = rule.Effect
 
 	return cop, nil
 }
 
+// decide replaces rule by a bool rule holding decision, decided by the
+// caller.
+func decide(rule *Rule, decision Decision) {
+	*rule = *constantRule(rule.Name, decision == True)
+}
+
 //nolint:gocognit
-func evaluateRule(rule *Rule, input map[string]string) error {
+func evaluateRule(rule *Rule, ev *evaluation) error {
+	if rule.Decision != Unevaluated {
+		return nil
+	}
 	if rule.Type == "bool" {
-		rule.BoolValue = rule.Operator
+		rule.Decision = decisionFor(rule.Operator == "true")
 
 		return nil
 	}
 	if rule.Type == "attribute" {
-		if rule.BoolValue != "" {
-			return nil
-		}
-		inputField, ok := input[rule.Attribute.Name]
-		if !ok {
-			rule.BoolValue = sqlCompileTarget(rule.Operator, rule.ParsedTarget, rule.Attribute)
-		} else {
-			boolValue := evaluateTarget(rule.Operator, rule.ParsedTarget, inputField, rule.Attribute.Kind)
-			rule.BoolValue = strconv.FormatBool(boolValue)
+		values, ok, decided, err := ev.resolve(rule, rule.Attribute.Name)
+		switch {
+		case err != nil:
+			return err
+		case decided.Decided():
+			decide(rule, decided)
+		case ok:
+			boolValue := evaluateValues(rule.Operator, rule.ParsedTarget, values, rule.Attribute.Kind, ev.now)
+			decide(rule, decisionFor(boolValue))
+		default:
+			rule.Decision = Residual
 		}
 
 		return nil
 	}
 
 	if rule.Type == "comparison" {
-		if rule.BoolValue != "" {
-			return nil
-		}
-
-		var attr1, attr2 RuleAttribute
-		attr1 = rule.Attributes[0]
-		attr2 = rule.Attributes[1]
-
-		t1 := attr1.Name
-		t2 := attr2.Name
+		rule.Resolved = map[string][]string{}
+		for _, attr := range rule.Attributes {
+			values, ok, decided, err := ev.resolve(rule, attr.Name)
+			if err != nil {
+				return err
+			}
+			if decided.Decided() {
+				decide(rule, decided)
 
-		_, ok := input[attr1.Name]
-		if ok {
-			t1 = input[attr1.Name]
-			if attr1.Kind == "string" {
-				t1 = "'" + input[attr1.Name] + "'"
+				return nil
+			}
+			if ok {
+				rule.Resolved[attr.Name] = values
 			}
 		}
-
-		_, ok = input[attr2.Name]
-		if ok {
-			t2 = input[attr2.Name]
-			if attr2.Kind == "string" {
-				t2 = "'" + input[attr2.Name] + "'"
+		rule.Decision = Residual
+		if len(rule.Attributes) == 2 {
+			left, leftResolved := rule.Resolved[rule.Attributes[0].Name]
+			right, rightResolved := rule.Resolved[rule.Attributes[1].Name]
+			if leftResolved && rightResolved {
+				boolValue := evaluateComparison(rule.Operator, left, right, kindOf(rule.Attributes[0]))
+				decide(rule, decisionFor(boolValue))
 			}
 		}
 
-		rule.BoolValue = fmt.Sprintf("%s = %s", t1, t2)
+		return nil
+	}
+
+	if rule.Type == "group" && rule.Operator == "NOT" {
+		if len(rule.Items) != 1 {
+			return fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+		}
+		ev.missingValue = !ev.missingValue
+		err := evaluateRule(&rule.Items[0], ev)
+		ev.missingValue = !ev.missingValue
+		if err != nil {
+			return err
+		}
+		switch rule.Items[0].Decision {
+		case True:
+			decide(rule, False)
+		case False:
+			decide(rule, True)
+		default:
+			rule.Decision = Residual
+		}
 
 		return nil
 	}
 
-	//nolint:nestif
 	if rule.Type == "group" {
-		if rule.BoolValue != "" {
-			return nil
-		}
-		cancelItems := false
 		allTrue := true
 		allFalse := true
 		for i := range rule.Items {
-			err := evaluateRule(&rule.Items[i], input)
+			err := evaluateRule(&rule.Items[i], ev)
 			if err != nil {
 				return err
 			}
-			if rule.Items[i].BoolValue == "false" && rule.Operator == "AND" {
-				rule.BoolValue = "false"
-				cancelItems = true
-				break
-			}
-			if rule.Items[i].BoolValue == "true" && rule.Operator == "OR" {
-				rule.BoolValue = "true"
-				cancelItems = true
-				break
-			}
-			if rule.Items[i].BoolValue == "false" {
+			switch decision := rule.Items[i].Decision; {
+			case decision == False && rule.Operator == "AND":
+				decide(rule, False)
+
+				return nil
+			case decision == True && rule.Operator == "OR":
+				decide(rule, True)
+
+				return nil
+			case decision == False:
 				allTrue = false
-			}
-			if rule.Items[i].BoolValue == "true" {
+			case decision == True:
 				allFalse = false
-			}
-			if rule.Items[i].BoolValue != "true" && rule.Items[i].BoolValue != "false" {
+			default:
 				allTrue = false
 				allFalse = false
 			}
 		}
-		if cancelItems {
-			rule.Items = nil
-		} else {
-			if allTrue && rule.Operator == "AND" {
-				rule.BoolValue = "true"
-				rule.Items = nil
-			}
-			if allFalse && rule.Operator == "OR" {
-				rule.BoolValue = "false"
-				rule.Items = nil
-			}
+		switch {
+		case allTrue && rule.Operator == "AND":
+			decide(rule, True)
+		case allFalse && rule.Operator == "OR":
+			decide(rule, False)
+		default:
+			rule.Decision = Residual
 		}
 
 		return nil
@@ -285,7 +625,9 @@ func cloneRule(src *Rule, dist *Rule) {
 	dist.Attributes = src.Attributes
 	dist.Assert = src.Assert
 	dist.ParsedTarget = src.ParsedTarget
-	dist.BoolValue = src.BoolValue
+	dist.Decision = src.Decision
+	dist.Resolved = src.Resolved
+	dist.Effect = src.Effect
 	dist.Operator = src.Operator
 	dist.Items = nil
 
@@ -88,6 +88,43 @@ func TestEvaluateTarget(t *testing.T) {
 			wantBool:  false,
 			wantError: false,
 		},
+		// matches_wildcard_1
+		{
+			name:     "matches_wildcard_1",
+			operator: "matchesWildcard",
+			target: &TargetWildcard{
+				Value: "/finance/*",
+			},
+			input:     "/finance/2024/q1",
+			kind:      "string",
+			wantBool:  true,
+			wantError: false,
+		},
+		// matches_wildcard_2, a wildcard matches the whole value.
+		{
+			name:     "matches_wildcard_2",
+			operator: "matchesWildcard",
+			target: &TargetWildcard{
+				Value: "finance",
+			},
+			input:     "/finance/2024/q1",
+			kind:      "string",
+			wantBool:  false,
+			wantError: false,
+		},
+		// matches_wildcard_3
+		{
+			name:     "matches_wildcard_3",
+			operator: "matchesWildcard",
+			target: &TargetWildcard{
+				Value:      "REPORT-??",
+				IgnoreCase: true,
+			},
+			input:     "report-07",
+			kind:      "string",
+			wantBool:  true,
+			wantError: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -108,7 +145,7 @@ func TestEvaluateRuleWithJson(t *testing.T) {
         "items": [
           {
             "type": "attribute",
-            "attribute": {"name": "userLevel", "kind": "number"},
+            "attribute": {"name": "user.level", "kind": "number"},
             "operator": "equal",
             "assert": {
               "value": "4"
@@ -120,7 +157,7 @@ func TestEvaluateRuleWithJson(t *testing.T) {
             "items": [
               {
                 "type": "attribute",
-                "attribute": {"name": "userLevel", "kind": "number"},
+                "attribute": {"name": "user.level", "kind": "number"},
                 "operator": "equal",
                 "assert": {
                   "value": "3"
@@ -128,7 +165,7 @@ func TestEvaluateRuleWithJson(t *testing.T) {
               },
               {
                 "type": "attribute",
-                "attribute": {"name": "secretLevel", "kind": "number"},
+                "attribute": {"name": "data.secretLevel", "kind": "number"},
                 "operator": "range",
                 "assert": {
                   "from": "1",
@@ -143,7 +180,7 @@ func TestEvaluateRuleWithJson(t *testing.T) {
             "items": [
               {
                 "type": "attribute",
-                "attribute": {"name": "userLevel", "kind": "number"},
+                "attribute": {"name": "user.level", "kind": "number"},
                 "operator": "equal",
                 "assert": {
                   "value": "2"
@@ -151,7 +188,7 @@ func TestEvaluateRuleWithJson(t *testing.T) {
               },
               {
                 "type": "attribute",
-                "attribute": {"name": "secretLevel", "kind": "number"},
+                "attribute": {"name": "data.secretLevel", "kind": "number"},
                 "operator": "range",
                 "assert": {
                   "from": "1",
@@ -166,7 +203,7 @@ func TestEvaluateRuleWithJson(t *testing.T) {
             "items": [
               {
                 "type": "attribute",
-                "attribute": {"name": "userLevel", "kind": "number"},
+                "attribute": {"name": "user.level", "kind": "number"},
                 "operator": "equal",
                 "assert": {
                   "value": "1"
@@ -174,7 +211,7 @@ func TestEvaluateRuleWithJson(t *testing.T) {
               },
               {
                 "type": "attribute",
-                "attribute": {"name": "secretLevel", "kind": "number"},
+                "attribute": {"name": "data.secretLevel", "kind": "number"},
                 "operator": "equal",
                 "assert": {
                   "value": "1"
@@ -195,25 +232,25 @@ func TestEvaluateRuleWithJson(t *testing.T) {
 		t.Errorf("failed to validate rule: %v", rErr)
 	}
 	rule, err = rule.Evaluate(map[string]string{
-		"userLevel":   "1",
-		"userCountry": "de",
+		"user.level":   "1",
+		"user.country": "de",
 	})
 	if err != nil {
 		t.Errorf("failed to evaluate rule: %v", err)
 	}
 
-	wantString := "( false OR ( false ) OR ( false ) OR ( true AND secretLevel = 1 ) )"
+	wantString := "( false OR false OR false OR ( true AND data.secretLevel = 1 ) )"
 	if rule.Stringer() != wantString {
 		t.Errorf("EvaluateRule() got = >%v<, want >%v<", rule.Stringer(), wantString)
 	}
 
 	rule, err = rule.Evaluate(map[string]string{
-		"secretLevel": "1",
+		"data.secretLevel": "1",
 	})
 	if err != nil {
 		t.Errorf("failed to evaluate rule: %v", err)
 	}
-	wantString = "( false OR ( false ) OR ( false ) OR ( true AND secretLevel = 1 ) )"
+	wantString = "( false OR false OR false OR ( true AND data.secretLevel = 1 ) )"
 	if rule.Stringer() != wantString {
 		t.Errorf("EvaluateRule() got = >%v<, want >%v<", rule.Stringer(), wantString)
 	}
@@ -237,7 +274,7 @@ func TestEvaluateRule(t *testing.T) {
 				Operator: "equal",
 				Attributes: []RuleAttribute{
 					{
-						Name: "doc.City",
+						Name: "data.City",
 						Kind: "string",
 					},
 					{
@@ -249,7 +286,7 @@ func TestEvaluateRule(t *testing.T) {
 			input: map[string]string{
 				"user.City": "New York",
 			},
-			wantString: `doc.City = 'New York'`,
+			wantString: `data.City = 'New York'`,
 			wantErr:    false,
 		},
 
@@ -261,7 +298,7 @@ func TestEvaluateRule(t *testing.T) {
 				Operator: "equal",
 				Attributes: []RuleAttribute{
 					{
-						Name: "doc.City",
+						Name: "data.City",
 						Kind: "string",
 					},
 					{
@@ -271,7 +308,7 @@ func TestEvaluateRule(t *testing.T) {
 				},
 			},
 			input:      map[string]string{},
-			wantString: `doc.City = user.City`,
+			wantString: `false`,
 			wantErr:    false,
 		},
 		////////////////////////////////////////////////////////////////////////////////////////
@@ -282,7 +319,7 @@ func TestEvaluateRule(t *testing.T) {
 				Operator: "equal",
 				Attributes: []RuleAttribute{
 					{
-						Name: "doc.Level",
+						Name: "data.Level",
 						Kind: "number",
 					},
 					{
@@ -294,7 +331,7 @@ func TestEvaluateRule(t *testing.T) {
 			input: map[string]string{
 				"user.Level": "25",
 			},
-			wantString: `doc.Level = 25`,
+			wantString: `data.Level = 25`,
 			wantErr:    false,
 		},
 
@@ -397,7 +434,7 @@ func TestEvaluateRule(t *testing.T) {
 				"user.age":    "25",
 				"user.gender": "male",
 			},
-			wantString: `( true )`,
+			wantString: `true`,
 			wantErr:    false,
 		},
 		////////////////////////////////////////////////////////////////////////////////////////
@@ -432,7 +469,7 @@ func TestEvaluateRule(t *testing.T) {
 				"user.age":    "26",
 				"user.gender": "male",
 			},
-			wantString: `( false )`,
+			wantString: `false`,
 			wantErr:    false,
 		},
 		////////////////////////////////////////////////////////////////////////////////////////
@@ -446,7 +483,7 @@ func TestEvaluateRule(t *testing.T) {
 						Type:     "attribute",
 						Operator: "equal",
 						Attribute: RuleAttribute{
-							Name: "user.age",
+							Name: "data.age",
 							Kind: "number",
 						},
 						Assert: json.RawMessage(`{"value": "25"}`),
@@ -465,7 +502,7 @@ func TestEvaluateRule(t *testing.T) {
 			input: map[string]string{
 				"user.gender": "male",
 			},
-			wantString: `( user.age = 25 AND true )`,
+			wantString: `( data.age = 25 AND true )`,
 			wantErr:    false,
 		},
 		////////////////////////////////////////////////////////////////////////////////////////
@@ -488,7 +525,7 @@ func TestEvaluateRule(t *testing.T) {
 						Type:     "attribute",
 						Operator: "equal",
 						Attribute: RuleAttribute{
-							Name: "user.gender",
+							Name: "data.gender",
 							Kind: "string",
 						},
 						Assert: json.RawMessage(`{"value": "male"}`),
@@ -498,7 +535,7 @@ func TestEvaluateRule(t *testing.T) {
 			input: map[string]string{
 				"user.age": "25",
 			},
-			wantString: `( true AND user.gender = "male" )`,
+			wantString: `( true AND data.gender = 'male' )`,
 			wantErr:    false,
 		},
 		////////////////////////////////////////////////////////////////////////////////////////
@@ -521,7 +558,7 @@ func TestEvaluateRule(t *testing.T) {
 						Type:     "attribute",
 						Operator: "equal",
 						Attribute: RuleAttribute{
-							Name: "user.gender",
+							Name: "data.gender",
 							Kind: "string",
 						},
 						Assert: json.RawMessage(`{"value": "male"}`),
@@ -531,7 +568,7 @@ func TestEvaluateRule(t *testing.T) {
 			input: map[string]string{
 				"user.age": "26",
 			},
-			wantString: `( false OR user.gender = "male" )`,
+			wantString: `( false OR data.gender = 'male' )`,
 			wantErr:    false,
 		},
 		////////////////////////////////////////////////////////////////////////////////////////
@@ -565,7 +602,7 @@ func TestEvaluateRuleMultipleTimes(t *testing.T) {
 				Type:     "attribute",
 				Operator: "equal",
 				Attribute: RuleAttribute{
-					Name: "user.age",
+					Name: "data.age",
 					Kind: "number",
 				},
 				Assert: json.RawMessage(`{"value": "25"}`),
@@ -584,7 +621,7 @@ func TestEvaluateRuleMultipleTimes(t *testing.T) {
 	input := map[string]string{
 		"user.gender": "male",
 	}
-	wantString := `( true AND user.age = 25 AND true )`
+	wantString := `( true AND data.age = 25 AND true )`
 	wantErr := false
 
 	if err := Validate(rule); err != nil {
@@ -602,9 +639,9 @@ func TestEvaluateRuleMultipleTimes(t *testing.T) {
 	}
 
 	input = map[string]string{
-		"user.age": "25",
+		"data.age": "25",
 	}
-	wantString = `( true AND user.age = 25 AND true )`
+	wantString = `( true AND data.age = 25 AND true )`
 	wantErr = false
 
 	rule, err = rule.Evaluate(input)
@@ -734,16 +771,844 @@ func TestEvaluateRuleWithJsonComplex(t *testing.T) {
 	}
 
 	rule, err = rule.Evaluate(map[string]string{
-		"userLevel":   "1",
-		"userCountry": "de",
-		"user.street": "street1",
+		"user.level":   "1",
+		"user.country": "de",
+		"user.street":  "street1",
 	})
 	if err != nil {
 		t.Errorf("failed to evaluate rule: %v", err)
 	}
 
-	wantString := `( true AND ( true ) AND data.work_order = "hello" AND ( ( data.work_order = "world" ) OR ( data.work_order = "world3" ) ) )`
+	wantString := `( true AND true AND data.work_order = 'hello' AND ( ( data.work_order = 'world' ) OR ( data.work_order = 'world3' ) ) )`
 	if rule.Stringer() != wantString {
 		t.Errorf("EvaluateRule() got = >%v<, want >%v<", rule.Stringer(), wantString)
 	}
 }
+
+func TestSQLBuilder(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      *Rule
+		input     map[string]string
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name: "equal with quote in value",
+			rule: &Rule{
+				Type:      "attribute",
+				Operator:  "equal",
+				Attribute: RuleAttribute{Name: "data.owner", Kind: "string"},
+				Assert:    json.RawMessage(`{"value": "O'Brien"}`),
+			},
+			input:     map[string]string{},
+			wantWhere: `"owner" = $1`,
+			wantArgs:  []any{"O'Brien"},
+		},
+		{
+			name: "range",
+			rule: &Rule{
+				Type:      "attribute",
+				Operator:  "range",
+				Attribute: RuleAttribute{Name: "data.level", Kind: "number"},
+				Assert:    json.RawMessage(`{"from": "1", "to": "3"}`),
+			},
+			input:     map[string]string{},
+			wantWhere: `"level" BETWEEN $1 AND $2`,
+			wantArgs:  []any{"1", "3"},
+		},
+		{
+			name: "group with resolved and pushed down items",
+			rule: &Rule{
+				Type:     "group",
+				Operator: "AND",
+				Items: []Rule{
+					{
+						Type:      "attribute",
+						Operator:  "equal",
+						Attribute: RuleAttribute{Name: "user.city", Kind: "string"},
+						Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+					},
+					{
+						Type:      "attribute",
+						Operator:  "equal",
+						Attribute: RuleAttribute{Name: "data.work_order", Kind: "string"},
+						Assert:    json.RawMessage(`{"value": "\"; drop table orders; --"}`),
+					},
+				},
+			},
+			input:     map[string]string{"user.city": "Hamburg"},
+			wantWhere: `( TRUE AND "work_order" = $1 )`,
+			wantArgs:  []any{`"; drop table orders; --`},
+		},
+		{
+			name: "comparison with resolved side",
+			rule: &Rule{
+				Type:     "comparison",
+				Operator: "equal",
+				Attributes: []RuleAttribute{
+					{Name: "data.city", Kind: "string"},
+					{Name: "user.city", Kind: "string"},
+				},
+			},
+			input:     map[string]string{"user.city": "O'Brien Town"},
+			wantWhere: `"city" = $1`,
+			wantArgs:  []any{"O'Brien Town"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			rule, err := tt.rule.Evaluate(tt.input)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			b := &SQLBuilder{}
+			where, err := b.Compile(rule)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), tt.wantArgs) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSQLBuilderSharedArgs(t *testing.T) {
+	rules := []*Rule{
+		{
+			Type:      "attribute",
+			Operator:  "equal",
+			Attribute: RuleAttribute{Name: "data.a", Kind: "string"},
+			Assert:    json.RawMessage(`{"value": "x"}`),
+		},
+		{
+			Type:      "attribute",
+			Operator:  "equal",
+			Attribute: RuleAttribute{Name: "data.b", Kind: "string"},
+			Assert:    json.RawMessage(`{"value": "y"}`),
+		},
+	}
+
+	b := &SQLBuilder{}
+	wheres := []string{}
+	for _, rule := range rules {
+		if err := Validate(rule); err != nil {
+			t.Fatalf("failed to validate rule: %v", err)
+		}
+		where, err := b.Compile(rule)
+		if err != nil {
+			t.Fatalf("Compile() error = %v", err)
+		}
+		wheres = append(wheres, where)
+	}
+
+	if wheres[1] != `"b" = $2` {
+		t.Errorf("Compile() got = >%v<, want >%v<", wheres[1], `"b" = $2`)
+	}
+	if !reflect.DeepEqual(b.Args(), []any{"x", "y"}) {
+		t.Errorf("Args() got = %v, want %v", b.Args(), []any{"x", "y"})
+	}
+}
+
+func TestSQLBuilderDialects(t *testing.T) {
+	rule := &Rule{
+		Type:     "group",
+		Operator: "OR",
+		Items: []Rule{
+			{
+				Type:     "bool",
+				Operator: "false",
+			},
+			{
+				Type:      "attribute",
+				Operator:  "equal",
+				Attribute: RuleAttribute{Name: "data.owner", Kind: "string"},
+				Assert:    json.RawMessage(`{"value": "O'Brien"}`),
+			},
+			{
+				Type:      "attribute",
+				Operator:  "isSubstringOf",
+				Attribute: RuleAttribute{Name: "data.city", Kind: "string"},
+				Assert:    json.RawMessage(`{"value": "New York"}`),
+			},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	tests := []struct {
+		dialect   string
+		wantWhere string
+	}{
+		{
+			dialect:   "postgres",
+			wantWhere: `( FALSE OR "owner" = $1 OR position("city" IN $2) > 0 )`,
+		},
+		{
+			dialect:   "mysql",
+			wantWhere: "( FALSE OR `owner` COLLATE utf8mb4_bin = ? OR LOCATE(`city` COLLATE utf8mb4_bin, ?) > 0 )",
+		},
+		{
+			dialect:   "sqlite",
+			wantWhere: `( 0 OR "owner" = ? OR instr(?, "city") > 0 )`,
+		},
+		{
+			dialect:   "sqlserver",
+			wantWhere: `( 1 = 0 OR [owner] COLLATE Latin1_General_BIN2 = @p1 OR (CHARINDEX([city] COLLATE Latin1_General_BIN2, @p2) > 0 OR DATALENGTH([city] COLLATE Latin1_General_BIN2) = 0) )`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			d, err := DialectByName(tt.dialect)
+			if err != nil {
+				t.Fatalf("DialectByName() error = %v", err)
+			}
+			b := &SQLBuilder{Dialect: d}
+			where, err := b.Compile(rule)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), []any{"O'Brien", "New York"}) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), []any{"O'Brien", "New York"})
+			}
+		})
+	}
+}
+
+func TestDialectQuoting(t *testing.T) {
+	tests := []struct {
+		dialect     Dialect
+		wantIdent   string
+		wantLiteral string
+		wantLike    string
+	}{
+		{Postgres, `"data"."we""ird"`, `'it''s \ here'`, `100!% a!_b !!`},
+		{MySQL, "`data`.`we\"ird`", `'it''s \\ here'`, `100!% a!_b !!`},
+		{SQLite, `"data"."we""ird"`, `'it''s \ here'`, `100!% a!_b !!`},
+		{SQLServer, `[data].[we"ird]`, `N'it''s \ here'`, `100!% a!_b !!`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			if got := tt.dialect.QuoteIdent(`data.we"ird`); got != tt.wantIdent {
+				t.Errorf("QuoteIdent() got = >%v<, want >%v<", got, tt.wantIdent)
+			}
+			if got := tt.dialect.QuoteLiteral(`it's \ here`); got != tt.wantLiteral {
+				t.Errorf("QuoteLiteral() got = >%v<, want >%v<", got, tt.wantLiteral)
+			}
+			if got := tt.dialect.EscapeLike(`100% a_b !`); got != tt.wantLike {
+				t.Errorf("EscapeLike() got = >%v<, want >%v<", got, tt.wantLike)
+			}
+		})
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"*", "anything", true},
+		{"a*", "abc", true},
+		{"*c", "abc", true},
+		{"a*c", "ac", true},
+		{"a*c", "abcbc", true},
+		{"a*c", "abcb", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"?", "ü", true},
+		{"*b*b*", "abcbd", true},
+		{"*b*b*", "abcd", false},
+		{"100%", "100%", true},
+		{"100%", "1000", false},
+		{"a_c", "abc", false},
+		{`a\*c`, "a*c", true},
+		{`a\*c`, "abc", false},
+		{`a\?c`, "abc", false},
+		{`a\\c`, `a\c`, true},
+		{`a\\c`, `a\\c`, false},
+		{`a\c`, "ac", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.value, func(t *testing.T) {
+			w, err := ParseWildcard(tt.pattern)
+			if err != nil {
+				t.Fatalf("ParseWildcard() error = %v", err)
+			}
+			if got := w.Match(tt.value); got != tt.want {
+				t.Errorf("Match() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseWildcard(`abc\`); err == nil {
+		t.Errorf("ParseWildcard() expected error for trailing escape")
+	}
+}
+
+func TestWildcardSQL(t *testing.T) {
+	rule := &Rule{
+		Type:      "attribute",
+		Operator:  "matchesWildcard",
+		Attribute: RuleAttribute{Name: "data.path", Kind: "string"},
+		Assert:    json.RawMessage(`{"value": "/fin_ance/100%/*.c?v"}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	tests := []struct {
+		dialect     Dialect
+		ignoreCase  bool
+		wantWhere   string
+		wantPattern string
+	}{
+		{Postgres, false, `"path" LIKE $1 ESCAPE '!'`, `/fin!_ance/100!%/%.c_v`},
+		{Postgres, true, `"path" ILIKE $1 ESCAPE '!'`, `/fin!_ance/100!%/%.c_v`},
+		{MySQL, false, "`path` LIKE ? COLLATE utf8mb4_bin ESCAPE '!'", `/fin!_ance/100!%/%.c_v`},
+		{SQLite, false, `"path" GLOB ?`, `/fin_ance/100%/*.c?v`},
+		{SQLite, true, `LOWER("path") LIKE LOWER(?) ESCAPE '!'`, `/fin!_ance/100!%/%.c_v`},
+		{SQLServer, false, `[path] COLLATE Latin1_General_CS_AS LIKE @p1 ESCAPE '!'`, `/fin!_ance/100!%/%.c_v`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			rule.ParsedTarget.(*TargetWildcard).IgnoreCase = tt.ignoreCase
+			b := &SQLBuilder{Dialect: tt.dialect}
+			where, err := b.Compile(rule)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), []any{tt.wantPattern}) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), []any{tt.wantPattern})
+			}
+		})
+	}
+}
+
+func TestWildcardSQLMultiByte(t *testing.T) {
+	// ? consumes one character, not one byte, in memory and in every
+	// dialect.
+	rule := &Rule{
+		Type:      "attribute",
+		Operator:  "matchesWildcard",
+		Attribute: RuleAttribute{Name: "data.street", Kind: "string"},
+		Assert:    json.RawMessage(`{"value": "Stra?e"}`),
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	w, err := rule.ParsedTarget.(*TargetWildcard).Wildcard()
+	if err != nil {
+		t.Fatalf("Wildcard() error = %v", err)
+	}
+	if !w.Match("Straße") || w.Match("Strae") {
+		t.Errorf("Match() got = %v, %v, want true, false", w.Match("Straße"), w.Match("Strae"))
+	}
+
+	tests := []struct {
+		dialect     Dialect
+		wantWhere   string
+		wantPattern string
+	}{
+		{Postgres, `"street" LIKE $1 ESCAPE '!'`, `Stra_e`},
+		{MySQL, "`street` LIKE ? COLLATE utf8mb4_bin ESCAPE '!'", `Stra_e`},
+		{SQLite, `"street" GLOB ?`, `Stra?e`},
+		{SQLServer, `[street] COLLATE Latin1_General_CS_AS LIKE @p1 ESCAPE '!'`, `Stra_e`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			b := &SQLBuilder{Dialect: tt.dialect}
+			where, err := b.Compile(rule)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), []any{tt.wantPattern}) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), []any{tt.wantPattern})
+			}
+		})
+	}
+}
+
+func TestAttributeOperators(t *testing.T) {
+	tests := []struct {
+		name      string
+		operator  string
+		kind      string
+		assert    string
+		input     string
+		wantBool  bool
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "notEqual",
+			operator:  "notEqual",
+			kind:      "string",
+			assert:    `{"value": "EU"}`,
+			input:     "UK",
+			wantBool:  true,
+			wantWhere: `"attr" <> $1`,
+			wantArgs:  []any{"EU"},
+		},
+		{
+			name:      "in",
+			operator:  "in",
+			kind:      "string",
+			assert:    `{"values": ["EU", "UK"]}`,
+			input:     "UK",
+			wantBool:  true,
+			wantWhere: `"attr" IN ($1, $2)`,
+			wantArgs:  []any{"EU", "UK"},
+		},
+		{
+			name:      "notIn",
+			operator:  "notIn",
+			kind:      "string",
+			assert:    `{"values": ["EU", "UK"]}`,
+			input:     "UK",
+			wantBool:  false,
+			wantWhere: `"attr" NOT IN ($1, $2)`,
+			wantArgs:  []any{"EU", "UK"},
+		},
+		{
+			name:      "greaterThan number",
+			operator:  "greaterThan",
+			kind:      "number",
+			assert:    `{"value": "3"}`,
+			input:     "10",
+			wantBool:  true,
+			wantWhere: `"attr" > $1`,
+			wantArgs:  []any{"3"},
+		},
+		{
+			name:      "greaterThan string",
+			operator:  "greaterThan",
+			kind:      "string",
+			assert:    `{"value": "3"}`,
+			input:     "10",
+			wantBool:  false,
+			wantWhere: `"attr" COLLATE "C" > $1`,
+			wantArgs:  []any{"3"},
+		},
+		{
+			name:      "lessThan",
+			operator:  "lessThan",
+			kind:      "number",
+			assert:    `{"value": "3"}`,
+			input:     "2.5",
+			wantBool:  true,
+			wantWhere: `"attr" < $1`,
+			wantArgs:  []any{"3"},
+		},
+		{
+			name:      "startsWith",
+			operator:  "startsWith",
+			kind:      "string",
+			assert:    `{"value": "/finance_"}`,
+			input:     "/finance_eu/q1",
+			wantBool:  true,
+			wantWhere: `"attr" LIKE $1 ESCAPE '!'`,
+			wantArgs:  []any{"/finance!_%"},
+		},
+		{
+			name:      "endsWith",
+			operator:  "endsWith",
+			kind:      "string",
+			assert:    `{"value": ".csv"}`,
+			input:     "report.CSV",
+			wantBool:  false,
+			wantWhere: `"attr" LIKE $1 ESCAPE '!'`,
+			wantArgs:  []any{"%.csv"},
+		},
+		{
+			name:      "isNull",
+			operator:  "isNull",
+			kind:      "string",
+			input:     "someone",
+			wantBool:  false,
+			wantWhere: `"attr" IS NULL`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &Rule{
+				Type:      "attribute",
+				Operator:  tt.operator,
+				Attribute: RuleAttribute{Name: "data.attr", Kind: tt.kind},
+			}
+			if tt.assert != "" {
+				rule.Assert = json.RawMessage(tt.assert)
+			}
+			if err := Validate(rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+
+			got := evaluateTarget(rule.Operator, rule.ParsedTarget, tt.input, tt.kind)
+			if got != tt.wantBool {
+				t.Errorf("evaluateTarget() got = %v, want %v", got, tt.wantBool)
+			}
+
+			b := &SQLBuilder{}
+			where, err := b.Compile(rule)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if where != tt.wantWhere {
+				t.Errorf("Compile() got = >%v<, want >%v<", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(b.Args(), tt.wantArgs) {
+				t.Errorf("Args() got = %v, want %v", b.Args(), tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestValidateOperators(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     *Rule
+		wantCode ErrorCode
+		wantErr  string
+	}{
+		{
+			name: "unknown operator",
+			rule: &Rule{
+				Name:      "r1",
+				Type:      "attribute",
+				Operator:  "sortOf",
+				Attribute: RuleAttribute{Name: "data.attr", Kind: "string"},
+				Assert:    json.RawMessage(`{"value": "x"}`),
+			},
+			wantCode: CodeAttributeOperatorUnknown,
+			wantErr:  "unknown operator `sortOf` for rule with type `attribute`",
+		},
+		{
+			name: "empty in",
+			rule: &Rule{
+				Name:      "r1",
+				Type:      "attribute",
+				Operator:  "in",
+				Attribute: RuleAttribute{Name: "data.attr", Kind: "string"},
+				Assert:    json.RawMessage(`{"values": []}`),
+			},
+			wantCode: CodeAssertValuesEmpty,
+			wantErr:  "rule with operator `in` must have at least one value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := []RuleError{{Name: "r1", Code: tt.wantCode, Err: tt.wantErr}}
+			if got := Validate(tt.rule); !reflect.DeepEqual(got, want) {
+				t.Errorf("Validate() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestEvaluateNotGroup(t *testing.T) {
+	notConfidential := Rule{
+		Type:     "group",
+		Operator: "NOT",
+		Items: []Rule{
+			{
+				Type:      "attribute",
+				Operator:  "equal",
+				Attribute: RuleAttribute{Name: "data.tag", Kind: "string"},
+				Assert:    json.RawMessage(`{"value": "confidential"}`),
+			},
+		},
+	}
+	tests := []struct {
+		name       string
+		rule       *Rule
+		input      map[string]string
+		wantString string
+		wantWhere  map[Dialect]string
+	}{
+		{
+			name: "residual",
+			rule: &notConfidential,
+			input: map[string]string{
+				"user.city": "Berlin",
+			},
+			wantString: `( NOT data.tag = 'confidential' )`,
+			wantWhere: map[Dialect]string{
+				Postgres:  `("tag" = $1) IS NOT TRUE`,
+				SQLServer: `CASE WHEN [tag] COLLATE Latin1_General_BIN2 = @p1 THEN 0 ELSE 1 END = 1`,
+			},
+		},
+		{
+			name: "decided true short circuits the outer group",
+			rule: &Rule{
+				Type:     "group",
+				Operator: "OR",
+				Items: []Rule{
+					{
+						Type:     "group",
+						Operator: "NOT",
+						Items: []Rule{
+							{
+								Type:      "attribute",
+								Operator:  "equal",
+								Attribute: RuleAttribute{Name: "user.city", Kind: "string"},
+								Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+							},
+						},
+					},
+					notConfidential,
+				},
+			},
+			input: map[string]string{
+				"user.city": "Berlin",
+			},
+			wantString: `true`,
+			wantWhere: map[Dialect]string{
+				Postgres: `TRUE`,
+			},
+		},
+		{
+			name: "decided false",
+			rule: &Rule{
+				Type:     "group",
+				Operator: "AND",
+				Items: []Rule{
+					{
+						Type:     "group",
+						Operator: "NOT",
+						Items: []Rule{
+							{
+								Type:     "bool",
+								Operator: "true",
+							},
+						},
+					},
+					notConfidential,
+				},
+			},
+			input:      map[string]string{},
+			wantString: `false`,
+			wantWhere: map[Dialect]string{
+				Postgres: `FALSE`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			rule, err := tt.rule.Evaluate(tt.input)
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			if rule.Stringer() != tt.wantString {
+				t.Errorf("Stringer() got = >%v<, want >%v<", rule.Stringer(), tt.wantString)
+			}
+			for d, want := range tt.wantWhere {
+				b := &SQLBuilder{Dialect: d}
+				where, err := b.Compile(rule)
+				if err != nil {
+					t.Fatalf("Compile() error = %v", err)
+				}
+				if where != want {
+					t.Errorf("Compile(%s) got = >%v<, want >%v<", d.Name(), where, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNotGroup(t *testing.T) {
+	rule := &Rule{
+		Name:     "not",
+		Type:     "group",
+		Operator: "NOT",
+		Items: []Rule{
+			{Name: "a", Type: "bool", Operator: "true"},
+			{Name: "b", Type: "bool", Operator: "false"},
+		},
+	}
+	want := []RuleError{{Name: "not", Code: CodeGroupNotItemCount, Err: "rule with type `group` and operator `NOT` must have exactly one item"}}
+	if got := Validate(rule); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() got = %v, want %v", got, want)
+	}
+}
+
+func TestEvaluateResidualJSON(t *testing.T) {
+	rule := &Rule{
+		Name:     "root",
+		Type:     "group",
+		Operator: "AND",
+		Items: []Rule{
+			{
+				Name:      "role",
+				Type:      "attribute",
+				Operator:  "equal",
+				Attribute: RuleAttribute{Name: "user.role"},
+				Assert:    json.RawMessage(`{"value": "staff"}`),
+			},
+			{
+				Name:       "department",
+				Type:       "comparison",
+				Operator:   "equal",
+				Attributes: []RuleAttribute{{Name: "data.department"}, {Name: "user.department"}},
+			},
+			{
+				Name:      "city",
+				Type:      "attribute",
+				Operator:  "equal",
+				Attribute: RuleAttribute{Name: "data.city"},
+				Assert:    json.RawMessage(`{"value": "Hamburg"}`),
+			},
+		},
+	}
+	if err := Validate(rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	evaluated, err := rule.EvaluateInput(Input{"user.role": {"staff"}, "user.department": {"sales"}})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+	if evaluated.Decision != Residual || evaluated.Items[0].Decision != True || evaluated.Items[0].Type != "bool" {
+		t.Fatalf("EvaluateInput() got decisions %v and %v %v, want residual and true bool", evaluated.Decision, evaluated.Items[0].Decision, evaluated.Items[0].Type)
+	}
+
+	data, err := json.Marshal(evaluated)
+	if err != nil {
+		t.Fatalf("failed to marshal residual: %v", err)
+	}
+	decoded := &Rule{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("failed to unmarshal residual: %v", err)
+	}
+	if err := Validate(decoded); err != nil {
+		t.Fatalf("failed to validate residual: %v", err)
+	}
+
+	want, err := (&SQLBuilder{}).Compile(evaluated)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	got, err := (&SQLBuilder{}).Compile(decoded)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Compile() got = >%v<, want >%v<", got, want)
+	}
+	if want := `( TRUE AND "department" = $1 AND "city" = $2 )`; got != want {
+		t.Errorf("Compile() got = >%v<, want >%v<", got, want)
+	}
+
+	// the residual is not evaluated again.
+	again, err := decoded.EvaluateInput(Input{})
+	if err != nil {
+		t.Fatalf("failed to evaluate residual: %v", err)
+	}
+	if want := `( true AND data.department = 'sales' AND data.city = 'Hamburg' )`; again.Stringer() != want {
+		t.Errorf("Stringer() got = >%v<, want >%v<", again.Stringer(), want)
+	}
+}
+
+func TestStringerInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *Rule
+		want string
+	}{
+		{
+			name: "bool",
+			rule: &Rule{Name: "a", Type: "bool", Operator: "maybe"},
+			want: "<rule `a` has invalid bool value `maybe`>",
+		},
+		{
+			name: "not validated",
+			rule: &Rule{Name: "b", Type: "attribute", Operator: "equal", Attribute: RuleAttribute{Name: "data.city"}},
+			want: "<rule `b` has no parsed target, was it validated?>",
+		},
+		{
+			name: "type",
+			rule: &Rule{Name: "c", Type: "unknown"},
+			want: "<rule `c` with type `unknown` can't be rendered inline>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Stringer(); got != tt.want {
+				t.Errorf("Stringer() got = >%v<, want >%v<", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecisionJSON(t *testing.T) {
+	for _, decision := range []Decision{Unevaluated, True, False, Residual} {
+		data, err := json.Marshal(decision)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var got Decision
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if got != decision {
+			t.Errorf("Unmarshal() got = %v, want %v", got, decision)
+		}
+	}
+	var got Decision
+	if err := json.Unmarshal([]byte(`"maybe"`), &got); err == nil {
+		t.Errorf("Unmarshal() error = nil, want error")
+	}
+}
+
+func TestValidatePaths(t *testing.T) {
+	policy := `{"name": "policy", "type": "group", "operator": "AND", "items": [
+		{"name": "city", "type": "attribute", "operator": "equal", "attribute": {"name": "data.city"}, "assert": {"value": "Berlin"}},
+		{"type": "group", "operator": "OR", "items": [
+			{"type": "group", "operator": "AND", "items": []},
+			{"name": "tags", "type": "attribute", "operator": "in", "attribute": {"name": "data.tags"}, "assert": {"values": 1}}
+		]}
+	]}`
+	rule := &Rule{}
+	if err := json.Unmarshal([]byte(policy), rule); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+
+	want := []RuleError{
+		{Path: "/items/1/items/0", Name: "MissingName", Code: CodeGroupEmpty, Err: "rule with type `group` rule must have at least one item"},
+		{Path: "/items/1/items/1", Name: "tags", Code: CodeAssertDecodeFailed, Err: "could not decode rule target"},
+	}
+	got := Validate(rule)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() got = %v, want %v", got, want)
+	}
+
+	data, err := json.Marshal(got[0])
+	if err != nil {
+		t.Fatalf("failed to marshal error: %v", err)
+	}
+	wantJSON := `{"path":"/items/1/items/0","name":"MissingName","code":"RULE_GROUP_EMPTY","error":"rule with type ` + "`group`" + ` rule must have at least one item"}`
+	if string(data) != wantJSON {
+		t.Errorf("json.Marshal() got = %s, want %s", data, wantJSON)
+	}
+}
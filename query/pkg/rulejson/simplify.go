@@ -0,0 +1,192 @@
+package rulejson
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+)
+
+// Simplify returns an equivalent, usually smaller copy of rule. It works
+// on validated as well as evaluated rules and
+//   - folds bool rules and decided items into their group,
+//   - flattens nested groups with the same operator,
+//   - unwraps groups with a single item and double negations,
+//   - removes duplicate items of a group, ignoring their names,
+//   - applies absorption, A OR (A AND B) is A and A AND (A OR B) is A.
+func (rule *Rule) Simplify() *Rule {
+	cop := &Rule{}
+	cloneRule(rule, cop)
+
+	result := simplifyRule(cop)
+	// the root may be replaced by an item or a constant.
+	result.Effect = rule.Effect
+
+	return result
+}
+
+func simplifyRule(rule *Rule) *Rule {
+	if rule.Type != "group" || isConstant(rule) {
+		return rule
+	}
+
+	items := make([]Rule, 0, len(rule.Items))
+	for i := range rule.Items {
+		items = append(items, *simplifyRule(&rule.Items[i]))
+	}
+
+	if rule.Operator == "NOT" {
+		if len(items) != 1 {
+			rule.Items = items
+
+			return rule
+		}
+		child := &items[0]
+		if value, ok := constantValue(child); ok {
+			return constantRule(rule.Name, !value)
+		}
+		if child.Type == "group" && child.Operator == "NOT" && len(child.Items) == 1 {
+			return &child.Items[0]
+		}
+		rule.Items = items
+
+		return rule
+	}
+
+	// the value that decides the group, false for AND and true for OR.
+	decisive := rule.Operator == "OR"
+	flat := make([]Rule, 0, len(items))
+	for i := range items {
+		item := &items[i]
+		if value, ok := constantValue(item); ok {
+			if value == decisive {
+				return constantRule(rule.Name, decisive)
+			}
+
+			continue
+		}
+		if item.Type == "group" && item.Operator == rule.Operator {
+			flat = append(flat, item.Items...)
+
+			continue
+		}
+		flat = append(flat, *item)
+	}
+
+	keys := make([]string, 0, len(flat))
+	unique := make([]Rule, 0, len(flat))
+	for i := range flat {
+		key := ruleKey(&flat[i])
+		if slices.Contains(keys, key) {
+			continue
+		}
+		keys = append(keys, key)
+		unique = append(unique, flat[i])
+	}
+
+	absorbed := make([]Rule, 0, len(unique))
+	for i := range unique {
+		if absorbs(&unique[i], rule.Operator, keys, i) {
+			continue
+		}
+		absorbed = append(absorbed, unique[i])
+	}
+
+	switch len(absorbed) {
+	case 0:
+		return constantRule(rule.Name, !decisive)
+	case 1:
+		return &absorbed[0]
+	}
+	rule.Items = absorbed
+
+	return rule
+}
+
+// absorbs reports whether item, a group with the dual operator of its
+// parent, contains one of its siblings and can therefore be dropped.
+func absorbs(item *Rule, operator string, siblings []string, index int) bool {
+	dual := map[string]string{"AND": "OR", "OR": "AND"}[operator]
+	if item.Type != "group" || item.Operator != dual {
+		return false
+	}
+	for i := range item.Items {
+		key := ruleKey(&item.Items[i])
+		for j, sibling := range siblings {
+			if j != index && sibling == key {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Constant reports whether rule is a bool rule or decided, and its value.
+// A constant false result of Evaluate or Combine allows no record.
+func (rule *Rule) Constant() (value bool, ok bool) {
+	return constantValue(rule)
+}
+
+// isConstant reports whether rule is decided, by its type or evaluation.
+func isConstant(rule *Rule) bool {
+	_, ok := constantValue(rule)
+
+	return ok
+}
+
+func constantValue(rule *Rule) (bool, bool) {
+	if rule.Decision.Decided() {
+		return rule.Decision == True, true
+	}
+	if rule.Type != "bool" {
+		return false, false
+	}
+	switch rule.Operator {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+
+	return false, false
+}
+
+func constantRule(name string, value bool) *Rule {
+	operator := "false"
+	if value {
+		operator = "true"
+	}
+
+	return &Rule{
+		Name:     name,
+		Type:     "bool",
+		Operator: operator,
+		Decision: decisionFor(value),
+	}
+}
+
+// ruleKey identifies rules that are equivalent apart from their names.
+func ruleKey(rule *Rule) string {
+	assert := &bytes.Buffer{}
+	if err := json.Compact(assert, rule.Assert); err != nil {
+		assert.Reset()
+		assert.Write(rule.Assert)
+	}
+	items := make([]string, len(rule.Items))
+	for i := range rule.Items {
+		items[i] = ruleKey(&rule.Items[i])
+	}
+
+	key, _ := json.Marshal(struct {
+		Type       string
+		Operator   string
+		Attribute  RuleAttribute
+		Attributes []RuleAttribute
+		Assert     string
+		Decision   Decision
+		Resolved   map[string][]string
+		Items      []string
+	}{rule.Type, rule.Operator, rule.Attribute, rule.Attributes, assert.String(), rule.Decision, rule.Resolved, items})
+
+	return string(key)
+}
@@ -0,0 +1,172 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func simplifyAttribute(name string, value string) Rule {
+	return Rule{
+		Name:      name,
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "data." + name},
+		Assert:    json.RawMessage(`{"value": "` + value + `"}`),
+	}
+}
+
+func simplifyGroup(operator string, items ...Rule) Rule {
+	return Rule{Type: "group", Operator: operator, Items: items}
+}
+
+func TestSimplify(t *testing.T) {
+	a := simplifyAttribute("a", "1")
+	b := simplifyAttribute("b", "2")
+	c := simplifyAttribute("c", "3")
+	yes := Rule{Type: "bool", Operator: "true"}
+	no := Rule{Type: "bool", Operator: "false"}
+
+	// a with a different name and assert formatting is still a duplicate.
+	aAgain := simplifyAttribute("a", "1")
+	aAgain.Name = "a again"
+	aAgain.Assert = json.RawMessage(`{ "value":"1" }`)
+
+	tests := []struct {
+		name       string
+		rule       Rule
+		wantString string
+	}{
+		{
+			name:       "single item groups",
+			rule:       simplifyGroup("OR", simplifyGroup("AND", a), simplifyGroup("AND", b)),
+			wantString: `( data.a = '1' OR data.b = '2' )`,
+		},
+		{
+			name:       "flatten",
+			rule:       simplifyGroup("AND", a, simplifyGroup("AND", b, simplifyGroup("AND", c))),
+			wantString: `( data.a = '1' AND data.b = '2' AND data.c = '3' )`,
+		},
+		{
+			name:       "duplicates",
+			rule:       simplifyGroup("OR", a, b, aAgain),
+			wantString: `( data.a = '1' OR data.b = '2' )`,
+		},
+		{
+			name:       "fold neutral constants",
+			rule:       simplifyGroup("AND", yes, a, simplifyGroup("OR", no, b)),
+			wantString: `( data.a = '1' AND data.b = '2' )`,
+		},
+		{
+			name:       "fold decisive constant",
+			rule:       simplifyGroup("OR", a, simplifyGroup("AND", b, yes), simplifyGroup("NOT", no)),
+			wantString: `true`,
+		},
+		{
+			name:       "only neutral constants",
+			rule:       simplifyGroup("OR", no, simplifyGroup("AND", no, a)),
+			wantString: `false`,
+		},
+		{
+			name:       "absorption in OR",
+			rule:       simplifyGroup("OR", a, simplifyGroup("AND", aAgain, b)),
+			wantString: `data.a = '1'`,
+		},
+		{
+			name:       "absorption in AND",
+			rule:       simplifyGroup("AND", simplifyGroup("OR", b, c), b, a),
+			wantString: `( data.b = '2' AND data.a = '1' )`,
+		},
+		{
+			name:       "double negation",
+			rule:       simplifyGroup("NOT", simplifyGroup("NOT", simplifyGroup("AND", a))),
+			wantString: `data.a = '1'`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(&tt.rule); err != nil {
+				t.Fatalf("failed to validate rule: %v", err)
+			}
+			simplified := tt.rule.Simplify()
+			evaluated, err := simplified.Evaluate(map[string]string{})
+			if err != nil {
+				t.Fatalf("failed to evaluate rule: %v", err)
+			}
+			if evaluated.Stringer() != tt.wantString {
+				t.Errorf("Simplify() got = >%v<, want >%v<", evaluated.Stringer(), tt.wantString)
+			}
+		})
+	}
+}
+
+func TestSimplifyEvaluated(t *testing.T) {
+	rule := simplifyGroup("OR",
+		simplifyGroup("AND", Rule{
+			Type:      "attribute",
+			Operator:  "equal",
+			Attribute: RuleAttribute{Name: "user.role"},
+			Assert:    json.RawMessage(`{"value": "staff"}`),
+		}, simplifyAttribute("a", "1")),
+		simplifyGroup("AND", simplifyAttribute("a", "1"), simplifyAttribute("b", "2")),
+	)
+	if err := Validate(&rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+	evaluated, err := rule.Evaluate(map[string]string{"user.role": "staff"})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+
+	want := `data.a = '1'`
+	if got := evaluated.Simplify().Stringer(); got != want {
+		t.Errorf("Simplify() got = >%v<, want >%v<", got, want)
+	}
+
+	b := &SQLBuilder{}
+	where, err := b.Compile(evaluated.Simplify())
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if want := `"a" = $1`; where != want {
+		t.Errorf("Compile() got = >%v<, want >%v<", where, want)
+	}
+}
+
+func TestConstant(t *testing.T) {
+	staff := Rule{
+		Name:      "staff",
+		Type:      "attribute",
+		Operator:  "equal",
+		Attribute: RuleAttribute{Name: "user.role"},
+		Assert:    json.RawMessage(`{"value": "staff"}`),
+	}
+	rule := simplifyGroup("OR", staff, simplifyGroup("AND", staff, simplifyAttribute("a", "1")))
+	if err := Validate(&rule); err != nil {
+		t.Fatalf("failed to validate rule: %v", err)
+	}
+
+	evaluated, err := rule.Evaluate(map[string]string{"user.role": "guest"})
+	if err != nil {
+		t.Fatalf("failed to evaluate rule: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		rule      *Rule
+		want      bool
+		wantConst bool
+	}{
+		{name: "residual", rule: &rule},
+		{name: "bool", rule: &Rule{Type: "bool", Operator: "true"}, want: true, wantConst: true},
+		{name: "every item false", rule: evaluated.Simplify(), wantConst: true},
+		{name: "no policy", rule: Combine(nil, DenyOverrides), wantConst: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.rule.Constant()
+			if got != tt.want || ok != tt.wantConst {
+				t.Errorf("Constant() got = %t, %t, want %t, %t", got, ok, tt.want, tt.wantConst)
+			}
+		})
+	}
+}
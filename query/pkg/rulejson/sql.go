@@ -0,0 +1,202 @@
+package rulejson
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SQLBuilder compiles evaluated rules into WHERE fragments that reference
+// assert and user values through placeholders ($1, ? or @p1 depending on
+// the dialect) instead of pasting them into the SQL text. Arguments are
+// collected across calls, so the fragments of several policies can be
+// joined into a single statement.
+//
+// A NULL data attribute matches neither an operator nor its negation,
+// only isNull and NOT. The other outputs keep this convention for missing
+// fields.
+type SQLBuilder struct {
+	// Dialect controls quoting and placeholders, defaults to Postgres.
+	Dialect Dialect
+	// Table, if set, qualifies the columns data attributes compile to.
+	Table string
+	// Clock, if set, fixes the time relative time operators compare
+	// against and binds it as a value. Otherwise the clock of the database
+	// is used.
+	Clock func() time.Time
+
+	// inline renders values as literals instead of binding them, used for
+	// the human readable output of Stringer.
+	inline bool
+	args   []any
+}
+
+// Args returns the arguments bound so far, in placeholder order.
+func (b *SQLBuilder) Args() []any {
+	return b.args
+}
+
+func (b *SQLBuilder) dialect() Dialect {
+	if b.Dialect == nil {
+		return Postgres
+	}
+
+	return b.Dialect
+}
+
+// bind returns the placeholder for value, or a literal of the given kind
+// when inlining.
+func (b *SQLBuilder) bind(value string, kind string) string {
+	return b.dialect().Value(kind, value, b.binder())
+}
+
+// bindAll binds each of values.
+func (b *SQLBuilder) bindAll(values []string, kind string) []string {
+	bound := make([]string, len(values))
+	for i := range values {
+		bound[i] = b.bind(values[i], kind)
+	}
+
+	return bound
+}
+
+// binder returns the bind function passed to the dialect, nil when
+// inlining.
+func (b *SQLBuilder) binder() func(any) string {
+	if b.inline {
+		return nil
+	}
+
+	return func(arg any) string {
+		b.args = append(b.args, arg)
+
+		return b.dialect().Placeholder(len(b.args))
+	}
+}
+
+// ident renders the column of a data attribute, data.city compiles to the
+// column city. The human readable output keeps names as written.
+func (b *SQLBuilder) ident(name string) string {
+	if b.inline {
+		return b.dialect().QuoteIdent(name)
+	}
+	column := strings.TrimPrefix(name, NamespaceData+".")
+	if b.Table != "" {
+		column = b.Table + "." + column
+	}
+
+	return b.dialect().QuoteIdent(column)
+}
+
+// column renders the column of attr for a comparison with op, string
+// columns with the binary collation of the dialect.
+func (b *SQLBuilder) column(attr RuleAttribute, op string) string {
+	if kindOf(attr) != KindString {
+		return b.ident(attr.Name)
+	}
+
+	return b.dialect().Collate(b.ident(attr.Name), !slices.Contains([]string{"=", "<>", "IN", "NOT IN"}, op))
+}
+
+func (b *SQLBuilder) like(name string, w Wildcard, ignoreCase bool) string {
+	pattern := b.bind(b.dialect().LikePattern(w, ignoreCase), "string")
+
+	return b.dialect().Like(b.ident(name), pattern, ignoreCase)
+}
+
+// Compile renders rule, usually the result of Evaluate, as a parameterized
+// WHERE fragment. Rules decided during evaluation are emitted as TRUE or
+// FALSE, the remaining ones are compiled from their asserts.
+func (b *SQLBuilder) Compile(rule *Rule) (string, error) {
+	if rule.Decision.Decided() {
+		return b.dialect().Bool(rule.Decision == True), nil
+	}
+
+	switch rule.Type {
+	case "bool":
+		return b.sqlBool(rule.Name, rule.Operator)
+	case "attribute":
+		return b.compileTarget(rule)
+	case "comparison":
+		return sqlCompileComparison(b, rule)
+	case "group":
+		if rule.Operator == "NOT" {
+			if len(rule.Items) != 1 {
+				return "", fmt.Errorf("rule `%s` with operator `NOT` must have exactly one item", rule.Name)
+			}
+			value, err := b.Compile(&rule.Items[0])
+			if err != nil {
+				return "", err
+			}
+
+			return b.dialect().Not(value), nil
+		}
+		values := []string{}
+		for i := range rule.Items {
+			value, err := b.Compile(&rule.Items[i])
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+
+		return "( " + strings.Join(values, " "+rule.Operator+" ") + " )", nil
+	}
+
+	return "", fmt.Errorf("allowed rule types are `attribute`, `bool`, `comparison` or `group` got: `%s`", rule.Type)
+}
+
+func (b *SQLBuilder) sqlBool(name string, value string) (string, error) {
+	switch value {
+	case "true":
+		return b.dialect().Bool(true), nil
+	case "false":
+		return b.dialect().Bool(false), nil
+	}
+
+	return "", fmt.Errorf("rule `%s` has invalid bool value `%s`", name, value)
+}
+
+func (b *SQLBuilder) compileTarget(rule *Rule) (string, error) {
+	if rule.ParsedTarget == nil {
+		return "", fmt.Errorf("rule `%s` has no parsed target, was it validated?", rule.Name)
+	}
+
+	if _, err := dataColumn(rule.Attribute.Name); err != nil {
+		return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+	where, err := sqlCompileTarget(b, rule.Operator, rule.ParsedTarget, rule.Attribute)
+	if err != nil {
+		return "", fmt.Errorf("rule `%s`: %w", rule.Name, err)
+	}
+
+	return where, nil
+}
+
+// plainDialect leaves identifiers as written in the policy, it backs the
+// human readable output of Stringer and can't be selected by name.
+type plainDialect struct {
+	postgresDialect
+}
+
+func (plainDialect) Name() string { return "plain" }
+
+func (plainDialect) QuoteIdent(name string) string { return name }
+
+func (plainDialect) Collate(expr string, _ bool) string { return expr }
+
+// inlineSQL renders an undecided attribute or comparison with its values
+// inlined, the form Stringer prints.
+func inlineSQL(rule *Rule) (string, error) {
+	b := &SQLBuilder{Dialect: plainDialect{}, inline: true}
+
+	switch rule.Type {
+	case "attribute":
+		return b.compileTarget(rule)
+	case "comparison":
+		return sqlCompileComparison(b, rule)
+	}
+
+	return "", fmt.Errorf("rule `%s` with type `%s` can't be rendered inline", rule.Name, rule.Type)
+}
@@ -0,0 +1,30 @@
+package rulejson
+
+import rego.v1
+
+default allow := false
+
+allow if {
+	true
+	policy_1
+	input.data.work_order == "hello"
+	policy_2
+}
+
+policy_1 if {
+	input.user.street != null
+	input.user.street == "street1"
+}
+
+policy_1 if {
+	input.user.city != null
+	input.user.city == "Berlin"
+}
+
+policy_2 if {
+	input.data.work_order == "world"
+}
+
+policy_2 if {
+	input.data.work_order == "world3"
+}
@@ -0,0 +1,34 @@
+package rulejson
+
+import rego.v1
+
+default allow := false
+
+allow if {
+	policy_1
+}
+
+policy_1 if {
+	input.user.level != null
+	input.user.level == 4
+}
+
+policy_1 if {
+	input.user.level != null
+	input.user.level == 3
+	input.data.secretLevel >= 1
+	input.data.secretLevel <= 3
+}
+
+policy_1 if {
+	input.user.level != null
+	input.user.level == 2
+	input.data.secretLevel >= 1
+	input.data.secretLevel <= 2
+}
+
+policy_1 if {
+	input.user.level != null
+	input.user.level == 1
+	input.data.secretLevel == 1
+}
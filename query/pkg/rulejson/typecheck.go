@@ -0,0 +1,156 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// kinds lists the attribute kinds, an empty kind is a string.
+var kinds = []string{KindString, KindNumber, KindDate, KindDateTime, KindDuration}
+
+// operatorKinds lists the kinds of the attributes an operator applies to,
+// operators that aren't listed apply to all kinds. greaterThan, lessThan
+// and the ordering comparisons also order strings by their bytes, SQL
+// compares them with the binary collation of the dialect. A range is an
+// interval of numbers, dates or durations.
+var operatorKinds = map[string][]string{
+	"range":           {KindNumber, KindDate, KindDateTime, KindDuration},
+	"isSubstringOf":   {KindString},
+	"startsWith":      {KindString},
+	"endsWith":        {KindString},
+	"matchesWildcard": {KindString},
+	"withinLast":      {KindDate, KindDateTime},
+	"olderThan":       {KindDate, KindDateTime},
+	"beforeNow":       {KindDate, KindDateTime},
+	"afterNow":        {KindDate, KindDateTime},
+}
+
+// assertShape is the set of fields of the assert of an operator.
+type assertShape struct {
+	required []string
+	optional []string
+}
+
+var (
+	valueShape    = assertShape{required: []string{"value"}}
+	relativeShape = assertShape{optional: []string{"duration"}}
+	// an empty list of values is reported like a missing one by validate.
+	valuesShape = assertShape{optional: []string{"values"}}
+)
+
+// assertShapes maps the operators of rules with type attribute to the
+// shape of their assert.
+var assertShapes = map[string]assertShape{
+	"equal":           valueShape,
+	"notEqual":        valueShape,
+	"greaterThan":     valueShape,
+	"lessThan":        valueShape,
+	"isSubstringOf":   valueShape,
+	"startsWith":      valueShape,
+	"endsWith":        valueShape,
+	"in":              valuesShape,
+	"notIn":           valuesShape,
+	"anyOf":           valuesShape,
+	"allOf":           valuesShape,
+	"intersects":      valuesShape,
+	"range":           {required: []string{"from", "to"}},
+	"matchesWildcard": {required: []string{"value"}, optional: []string{"ignoreCase"}},
+	"isNull":          {},
+	"withinLast":      relativeShape,
+	"olderThan":       relativeShape,
+	"beforeNow":       relativeShape,
+	"afterNow":        relativeShape,
+}
+
+// typeCheck checks the kinds of the attributes of rule against its
+// operator and the fields of its assert. Unknown operators are reported by
+// validate.
+func typeCheck(rule *Rule, errs *[]RuleError) {
+	attrs, target := []RuleAttribute{rule.Attribute}, "an attribute"
+	if rule.Type == "comparison" {
+		attrs, target = rule.Attributes, "attributes"
+	}
+	known := len(attrs) != 0
+	for _, attr := range attrs {
+		known = typeCheckKind(rule, attr, errs) && known
+	}
+	// attributes of different kinds are reported by validateComparison.
+	if allowed, ok := operatorKinds[rule.Operator]; ok && known && !slices.Contains(allowed, kindOf(attrs[0])) {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeOperatorKindInvalid,
+			Err:  "rule with operator `" + rule.Operator + "` must have " + target + " of kind " + kindList(allowed),
+		})
+	}
+
+	if rule.Type == "attribute" {
+		typeCheckAssert(rule, errs)
+	}
+}
+
+// typeCheckKind reports an unknown kind of attr.
+func typeCheckKind(rule *Rule, attr RuleAttribute, errs *[]RuleError) bool {
+	if attr.Kind == "" || slices.Contains(kinds, attr.Kind) {
+		return true
+	}
+	*errs = append(*errs, RuleError{
+		Name: rule.Name,
+		Code: CodeKindUnknown,
+		Err:  "attribute `" + attr.Name + "` has unknown kind `" + attr.Kind + "`, must be " + kindList(kinds),
+	})
+
+	return false
+}
+
+// typeCheckAssert reports missing and unknown fields of the assert, an
+// assert that isn't an object is reported by validate.
+func typeCheckAssert(rule *Rule, errs *[]RuleError) {
+	shape, ok := assertShapes[rule.Operator]
+	if !ok || rule.Assert == nil {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rule.Assert, &fields); err != nil || fields == nil {
+		return
+	}
+
+	for _, field := range shape.required {
+		if _, ok := fields[field]; !ok {
+			*errs = append(*errs, RuleError{
+				Name: rule.Name,
+				Code: CodeAssertMissing,
+				Err:  "rule with operator `" + rule.Operator + "` must have field `" + field + "` set",
+			})
+		}
+	}
+
+	unknown := make([]string, 0, len(fields))
+	for field := range fields {
+		if !slices.Contains(shape.required, field) && !slices.Contains(shape.optional, field) {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		*errs = append(*errs, RuleError{
+			Name: rule.Name,
+			Code: CodeAssertFieldUnknown,
+			Err:  "rule with operator `" + rule.Operator + "` has unknown field `" + field + "`",
+		})
+	}
+}
+
+// kindList renders kinds like `date` or `datetime`.
+func kindList(kinds []string) string {
+	quoted := make([]string, len(kinds))
+	for i := range kinds {
+		quoted[i] = "`" + kinds[i] + "`"
+	}
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
@@ -0,0 +1,142 @@
+package rulejson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTypeCheck(t *testing.T) {
+	attribute := func(kind string, operator string, assert string) *Rule {
+		rule := &Rule{
+			Name:      "r1",
+			Type:      "attribute",
+			Operator:  operator,
+			Attribute: RuleAttribute{Name: "data.attr", Kind: kind},
+		}
+		if assert != "" {
+			rule.Assert = json.RawMessage(assert)
+		}
+
+		return rule
+	}
+	comparison := func(operator string, left string, right string) *Rule {
+		return &Rule{
+			Name:       "r1",
+			Type:       "comparison",
+			Operator:   operator,
+			Attributes: []RuleAttribute{{Name: "data.a", Kind: left}, {Name: "user.b", Kind: right}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		rule *Rule
+		want []RuleError
+	}{
+		{
+			name: "range of numbers",
+			rule: attribute(KindNumber, "range", `{"from": "1", "to": "3"}`),
+		},
+		{
+			name: "range of strings",
+			rule: attribute("", "range", `{"from": "a", "to": "c"}`),
+			want: []RuleError{{Name: "r1", Code: CodeOperatorKindInvalid, Err: "rule with operator `range` must have an attribute of kind `number`, `date`, `datetime` or `duration`"}},
+		},
+		{
+			name: "greaterThan orders strings",
+			rule: attribute(KindString, "greaterThan", `{"value": "m"}`),
+		},
+		{
+			name: "isSubstringOf a number",
+			rule: attribute(KindNumber, "isSubstringOf", `{"value": "123"}`),
+			want: []RuleError{{Name: "r1", Code: CodeOperatorKindInvalid, Err: "rule with operator `isSubstringOf` must have an attribute of kind `string`"}},
+		},
+		{
+			name: "matchesWildcard a date",
+			rule: attribute(KindDate, "matchesWildcard", `{"value": "2024-*"}`),
+			want: []RuleError{{Name: "r1", Code: CodeOperatorKindInvalid, Err: "rule with operator `matchesWildcard` must have an attribute of kind `string`"}},
+		},
+		{
+			name: "unknown kind",
+			rule: attribute("money", "equal", `{"value": "1"}`),
+			want: []RuleError{{Name: "r1", Code: CodeKindUnknown, Err: "attribute `data.attr` has unknown kind `money`, must be `string`, `number`, `date`, `datetime` or `duration`"}},
+		},
+		{
+			name: "values for a single value operator",
+			rule: attribute("", "equal", `{"values": ["a"]}`),
+			want: []RuleError{
+				{Name: "r1", Code: CodeAssertMissing, Err: "rule with operator `equal` must have field `value` set"},
+				{Name: "r1", Code: CodeAssertFieldUnknown, Err: "rule with operator `equal` has unknown field `values`"},
+			},
+		},
+		{
+			name: "range without upper bound",
+			rule: attribute(KindNumber, "range", `{"from": "1"}`),
+			want: []RuleError{
+				{Name: "r1", Code: CodeAssertMissing, Err: "rule with operator `range` must have field `to` set"},
+				{Name: "r1", Code: CodeAssertValueInvalid, Err: "assert value `` is not a valid number"},
+			},
+		},
+		{
+			name: "matchesWildcard ignoreCase",
+			rule: attribute("", "matchesWildcard", `{"value": "J*", "ignoreCase": true}`),
+		},
+		{
+			name: "isNull with a value",
+			rule: attribute("", "isNull", `{"value": "a"}`),
+			want: []RuleError{{Name: "r1", Code: CodeAssertFieldUnknown, Err: "rule with operator `isNull` has unknown field `value`"}},
+		},
+		{
+			name: "comparison of numbers",
+			rule: comparison("lte", KindNumber, KindNumber),
+		},
+		{
+			name: "comparison of unknown kinds",
+			rule: comparison("equal", "money", "money"),
+			want: []RuleError{
+				{Name: "r1", Code: CodeKindUnknown, Err: "attribute `data.a` has unknown kind `money`, must be `string`, `number`, `date`, `datetime` or `duration`"},
+				{Name: "r1", Code: CodeKindUnknown, Err: "attribute `user.b` has unknown kind `money`, must be `string`, `number`, `date`, `datetime` or `duration`"},
+			},
+		},
+		{
+			name: "comparison of different kinds",
+			rule: comparison("equal", KindNumber, KindDate),
+			want: []RuleError{{Name: "r1", Code: CodeComparisonKindMismatch, Err: "rule with type `comparison` must compare attributes of the same kind"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Validate(tt.rule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypeCheckReportsEveryMismatch(t *testing.T) {
+	policy := `{"type": "group", "operator": "OR", "items": [
+		{"name": "a", "type": "attribute", "operator": "range", "attribute": {"name": "data.code"}, "assert": {"from": "a", "to": "b"}},
+		{"type": "group", "operator": "AND", "items": [
+			{"name": "b", "type": "attribute", "operator": "startsWith", "attribute": {"name": "data.age", "kind": "number"}, "assert": {"value": "1"}},
+			{"name": "c", "type": "attribute", "operator": "withinLast", "attribute": {"name": "data.created", "kind": "timestamp"}, "assert": {"duration": "P7D"}}
+		]}
+	]}`
+	rule := &Rule{}
+	if err := json.Unmarshal([]byte(policy), rule); err != nil {
+		t.Fatalf("failed to unmarshal json: %v", err)
+	}
+
+	var got []string
+	for _, rErr := range Validate(rule) {
+		got = append(got, rErr.Path+" "+string(rErr.Code))
+	}
+	want := []string{
+		"/items/0 OPERATOR_KIND_INVALID",
+		"/items/1/items/0 OPERATOR_KIND_INVALID",
+		"/items/1/items/1 KIND_UNKNOWN",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package rulejson
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Wildcard is a parsed pattern of the matchesWildcard operator. In the
+// pattern `*` matches any sequence of characters, `?` matches exactly one
+// character and a backslash makes the following character literal. Every
+// other character, including the LIKE metacharacters `%` and `_`, matches
+// itself.
+type Wildcard []wildcardToken
+
+type wildcardKind int
+
+const (
+	wildcardLiteral wildcardKind = iota
+	wildcardAny
+	wildcardOne
+)
+
+type wildcardToken struct {
+	kind    wildcardKind
+	literal string
+}
+
+// ParseWildcard parses pattern, it fails on a trailing backslash.
+func ParseWildcard(pattern string) (Wildcard, error) {
+	var (
+		w       Wildcard
+		literal strings.Builder
+	)
+	flush := func() {
+		if literal.Len() > 0 {
+			w = append(w, wildcardToken{kind: wildcardLiteral, literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i == len(pattern)-1 {
+				return nil, errors.New("wildcard pattern ends with an escape character")
+			}
+			i++
+			_, size := utf8.DecodeRuneInString(pattern[i:])
+			literal.WriteString(pattern[i : i+size])
+			i += size - 1
+		case '*':
+			flush()
+			// consecutive stars are equivalent to a single one.
+			if len(w) == 0 || w[len(w)-1].kind != wildcardAny {
+				w = append(w, wildcardToken{kind: wildcardAny})
+			}
+		case '?':
+			flush()
+			w = append(w, wildcardToken{kind: wildcardOne})
+		default:
+			literal.WriteByte(pattern[i])
+		}
+	}
+	flush()
+
+	return w, nil
+}
+
+// Match reports whether value matches the whole pattern.
+func (w Wildcard) Match(value string) bool {
+	// position after the last star and the offset it was tried at, a
+	// mismatch retries there with the star consuming one more character.
+	star, starOffset := -1, 0
+	i, offset := 0, 0
+
+	for {
+		if i < len(w) {
+			token := w[i]
+			switch token.kind {
+			case wildcardAny:
+				i++
+				star, starOffset = i, offset
+
+				continue
+			case wildcardOne:
+				if offset < len(value) {
+					_, size := utf8.DecodeRuneInString(value[offset:])
+					i, offset = i+1, offset+size
+
+					continue
+				}
+			case wildcardLiteral:
+				if strings.HasPrefix(value[offset:], token.literal) {
+					i, offset = i+1, offset+len(token.literal)
+
+					continue
+				}
+			}
+		} else if offset == len(value) {
+			return true
+		}
+
+		if star < 0 || starOffset == len(value) {
+			return false
+		}
+		_, size := utf8.DecodeRuneInString(value[starOffset:])
+		starOffset += size
+		i, offset = star, starOffset
+	}
+}
+
+// translate renders w in another pattern syntax, escaping literals with
+// escape and replacing the wildcards with many and one.
+func (w Wildcard) translate(escape func(string) string, many string, one string) string {
+	var b strings.Builder
+	for _, token := range w {
+		switch token.kind {
+		case wildcardLiteral:
+			b.WriteString(escape(token.literal))
+		case wildcardAny:
+			b.WriteString(many)
+		case wildcardOne:
+			b.WriteString(one)
+		}
+	}
+
+	return b.String()
+}
+
+// likePattern translates w into a LIKE pattern, escaping literals with
+// escape.
+func (w Wildcard) likePattern(escape func(string) string) string {
+	return w.translate(escape, "%", "_")
+}
+
+var globReplacer = strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+
+// globPattern translates w into a SQLite GLOB pattern.
+func (w Wildcard) globPattern() string {
+	return w.translate(globReplacer.Replace, "*", "?")
+}
+
+// regexPattern translates w into a regular expression matching whole
+// values.
+func (w Wildcard) regexPattern() string {
+	return `^` + w.translate(regexp.QuoteMeta, ".*", ".") + `\z`
+}